@@ -0,0 +1,208 @@
+package newsaggregator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/mongodb"
+)
+
+// DaySource is a source with per day article listings, it's able to fetch
+// articles of an arbitrary day and so can be backfilled. Only year, month
+// and day of the given day are used, the source applies its own timezone.
+type DaySource interface {
+	Source
+	DayArticles(day time.Time, fas chan<- entity.FetchedArticle) error
+}
+
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Backfill loads source articles of days from from to to inclusive. After
+// each day checkpoint of the range is stored, so interrupted backfill of
+// the same range resumes from the next day. Delay is waited between days
+// to not disturb live ingestion. Backfill returns when done or stop is
+// closed, the day stopped in the middle is loaded again on resume.
+// Backfilled articles are marked as such and are kept regardless of
+// retention.
+func (na *NewsAggregator) Backfill(sourceName string, from, to time.Time,
+	delay time.Duration, stop <-chan struct{}) error {
+
+	var ds DaySource
+
+	for _, s := range na.sources {
+		if s.Name() == sourceName {
+			var isDaySource bool
+			ds, isDaySource = s.(DaySource)
+			if !isDaySource {
+				return errors.New("source doesn't support backfill")
+			}
+		}
+	}
+
+	if ds == nil {
+		return errors.New("source not found")
+	}
+
+	from, to = dayOf(from), dayOf(to)
+
+	if to.Before(from) {
+		return errors.New("to is before from")
+	}
+
+	log := na.log.WithFields(logrus.Fields{
+		"source_name": sourceName,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+	})
+
+	day := from
+
+	c, err := na.store.BackfillCheckpoint(sourceName, from, to)
+	switch {
+	case err == nil:
+		day = dayOf(c.LastDay).AddDate(0, 0, 1)
+		log.WithField("last_day", c.LastDay.Format("2006-01-02")).
+			Info("resuming backfill from checkpoint")
+	case err != mongodb.ErrNotFound:
+		return errors.New("failed to get backfill checkpoint: " + err.Error())
+	}
+
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		stopped, err := na.backfillDay(ds, day, stop)
+		if err != nil {
+			return errors.New("failed to backfill day " +
+				day.Format("2006-01-02") + ": " + err.Error())
+		}
+
+		if stopped {
+			log.WithField("day", day.Format("2006-01-02")).
+				Info("backfill stopped in the middle of the day")
+			return nil
+		}
+
+		err = na.store.SetBackfillCheckpoint(entity.BackfillCheckpoint{
+			SourceName: sourceName,
+			From:       from,
+			To:         to,
+			LastDay:    day,
+			UpdatedAt:  time.Now(),
+		})
+		if err != nil {
+			return errors.New("failed to set backfill checkpoint: " +
+				err.Error())
+		}
+
+		if day.Equal(to) {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			log.WithField("last_day", day.Format("2006-01-02")).
+				Info("backfill stopped")
+			return nil
+		}
+	}
+
+	log.Info("backfill finished")
+
+	return nil
+}
+
+// backfillDay loads source articles of the day. It's stopped between
+// articles when stop is closed, the articles loaded so far are stored then.
+func (na *NewsAggregator) backfillDay(s DaySource, day time.Time,
+	stop <-chan struct{}) (stopped bool, err error) {
+
+	r := entity.FetchReport{
+		SourceName: s.Name(),
+		StartedAt:  time.Now(),
+	}
+
+	var (
+		fas      = make(chan entity.FetchedArticle)
+		errs     = make(chan error, 1)
+		batch    []entity.Article
+		storeErr error
+	)
+
+	go func() {
+		errs <- s.DayArticles(day, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		select {
+		case <-stop:
+			stopped = true
+		default:
+		}
+
+		if stopped {
+			break
+		}
+
+		if storeErr != nil || (fa.Err == nil && fa.Article.URL == "") {
+			continue
+		}
+
+		r.Seen++
+
+		if fa.Err != nil {
+			r.Failed++
+			if len(r.Failures) < maxReportFailures {
+				r.Failures = append(r.Failures, entity.FetchFailure{
+					URL:    fa.Article.URL,
+					Reason: fa.Err.Error(),
+				})
+			}
+			continue
+		}
+
+		fa.Article.Backfilled = true
+
+		batch = append(batch, fa.Article)
+
+		if len(batch) >= streamBatchSize {
+			storeErr = na.storeArticles(s.Name(), batch, "", "", &r)
+			batch = nil
+		}
+	}
+
+	if storeErr == nil {
+		storeErr = na.storeArticles(s.Name(), batch, "", "", &r)
+	}
+
+	if stopped {
+		// Rest of the day is fetched by the source anyway, it's
+		// discarded in background.
+		go func() {
+			for range fas {
+			}
+			<-errs
+		}()
+		err = storeErr
+	} else {
+		err = <-errs
+		if err == nil {
+			err = storeErr
+		}
+	}
+
+	r.FinishedAt = time.Now()
+	r.SelectorCounts = selectorCounts(s)
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	na.reportFetch(r)
+
+	return stopped, err
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,10 +15,22 @@ import (
 func main() {
 	logrus.SetLevel(logrus.DebugLevel)
 
-	newsAggr, err := newsaggregator.NewNewsAggregator(
-		os.Getenv("NEWS_AGGREGATOR_MONGODB_URI"),
-		os.Getenv("NEWS_AGGREGATOR_MYSTEM_BIN_PATH"),
-		os.Getenv("NEWS_AGGREGATOR_WEB_SERVER_BIND_ADDR"))
+	config := newsaggregator.DefaultConfig()
+
+	if configPath := os.Getenv("NEWS_AGGREGATOR_CONFIG_PATH"); configPath != "" {
+		var err error
+		config, err = newsaggregator.LoadConfig(configPath)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load config")
+		}
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		backfill(config, os.Args[2:])
+		return
+	}
+
+	newsAggr, err := newNewsAggregator(config)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to create news aggregator")
 	}
@@ -29,10 +42,7 @@ func main() {
 
 	time.Sleep(200 * time.Millisecond)
 
-	ss := make(chan os.Signal)
-	signal.Notify(ss, os.Interrupt, syscall.SIGTERM)
-
-	s := <-ss
+	s := <-signals()
 
 	logrus.Infof("captured %v signal, stopping", s)
 
@@ -45,3 +55,80 @@ func main() {
 	logrus.Infof("stopped in %g seconds, exiting",
 		et.Sub(st).Seconds())
 }
+
+func newNewsAggregator(config newsaggregator.Config) (
+	*newsaggregator.NewsAggregator, error) {
+
+	return newsaggregator.NewNewsAggregator(
+		os.Getenv("NEWS_AGGREGATOR_MONGODB_URI"),
+		os.Getenv("NEWS_AGGREGATOR_MYSTEM_BIN_PATH"),
+		os.Getenv("NEWS_AGGREGATOR_WEB_SERVER_BIND_ADDR"),
+		config)
+}
+
+func signals() chan os.Signal {
+	ss := make(chan os.Signal, 1)
+	signal.Notify(ss, os.Interrupt, syscall.SIGTERM)
+	return ss
+}
+
+func backfill(config newsaggregator.Config, args []string) {
+	const dateLayout = "2006-01-02"
+
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+
+	var (
+		sourceName = fs.String("source", "", "name of source to backfill")
+		fromStr    = fs.String("from", "", "first day, YYYY-MM-DD")
+		toStr      = fs.String("to", "", "last day, YYYY-MM-DD, "+
+			"defaults to yesterday")
+		delay = fs.Duration("delay", 10*time.Second,
+			"delay between days")
+		rateLimit = fs.Float64("rate", 1,
+			"requests per second limit for each host, 0 keeps config one")
+	)
+
+	fs.Parse(args)
+
+	if *sourceName == "" || *fromStr == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	from, err := time.Parse(dateLayout, *fromStr)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to parse from")
+	}
+
+	to := time.Now().AddDate(0, 0, -1)
+
+	if *toStr != "" {
+		to, err = time.Parse(dateLayout, *toStr)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to parse to")
+		}
+	}
+
+	if *rateLimit > 0 {
+		config.Fetcher.RateLimit = *rateLimit
+		config.Fetcher.HostRateLimits = nil
+	}
+
+	newsAggr, err := newNewsAggregator(config)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to create news aggregator")
+	}
+
+	stop := make(chan struct{})
+
+	go func() {
+		s := <-signals()
+		logrus.Infof("captured %v signal, stopping backfill", s)
+		close(stop)
+	}()
+
+	err = newsAggr.Backfill(*sourceName, from, to, *delay, stop)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to backfill")
+	}
+}
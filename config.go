@@ -0,0 +1,239 @@
+package newsaggregator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/lentaru"
+)
+
+// Config of news aggregator. Articles older than Retention are removed
+// except the backfilled ones, zero Retention means default one week.
+type Config struct {
+	Retention Duration       `json:"retention"`
+	Recrawl   RecrawlConfig  `json:"recrawl"`
+	Fetcher   FetcherConfig  `json:"fetcher"`
+	Sources   []SourceConfig `json:"sources"`
+	Ingest    IngestConfig   `json:"ingest"`
+}
+
+// RecrawlConfig controls refetching of the recently published articles
+// to track their revisions. Articles younger than MaxAge are refetched
+// every Interval, zero MaxAge disables refetching.
+type RecrawlConfig struct {
+	MaxAge   Duration `json:"maxAge"`
+	Interval Duration `json:"interval"`
+}
+
+const defaultRecrawlInterval = 10 * time.Minute
+
+func (rc RecrawlConfig) withDefaults() RecrawlConfig {
+	if rc.Interval == 0 {
+		rc.Interval = Duration(defaultRecrawlInterval)
+	}
+	return rc
+}
+
+func (rc RecrawlConfig) validate() error {
+	switch {
+	case rc.MaxAge < 0:
+		return errors.New("negative max age")
+	case rc.Interval < 0:
+		return errors.New("negative interval")
+	}
+	return nil
+}
+
+// IngestConfig configures push ingestion API. Tokens maps bearer tokens of
+// the API callers to their source names. Ingestion is disabled when there
+// are no tokens. Tokens should be random strings of at least
+// minIngestTokenSize characters, placeholders like "change-me" are
+// rejected.
+type IngestConfig struct {
+	Tokens map[string]string `json:"tokens"`
+}
+
+const minIngestTokenSize = 16
+
+var ingestTokenPlaceholders = []string{"change-me", "changeme", "placeholder"}
+
+func isPlaceholderToken(token string) bool {
+	token = strings.ToLower(token)
+	for _, p := range ingestTokenPlaceholders {
+		if strings.Contains(token, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ic IngestConfig) validate(scs []SourceConfig) error {
+	names := map[string]struct{}{}
+
+	for _, sc := range scs {
+		names[sc.Name] = struct{}{}
+	}
+
+	for token, name := range ic.Tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("source `%s`: empty token", name)
+		case isPlaceholderToken(token):
+			return fmt.Errorf("source `%s`: placeholder token", name)
+		case len(token) < minIngestTokenSize:
+			return fmt.Errorf("source `%s`: token is shorter than %d "+
+				"characters", name, minIngestTokenSize)
+		case name == "":
+			return errors.New("empty source name")
+		}
+
+		if _, exists := names[name]; exists {
+			return fmt.Errorf("source `%s` is already configured as "+
+				"fetched one", name)
+		}
+	}
+
+	return nil
+}
+
+// FetcherConfig configures HTTP fetcher shared by all sources, zero
+// values mean fetcher defaults. Retries -1 disables retries.
+type FetcherConfig struct {
+	UserAgent          string             `json:"userAgent"`
+	Timeout            Duration           `json:"timeout"`
+	Retries            int                `json:"retries"`
+	RetryBackoff       Duration           `json:"retryBackoff"`
+	RateLimit          float64            `json:"rateLimit"`
+	RateBurst          int                `json:"rateBurst"`
+	HostRateLimits     map[string]float64 `json:"hostRateLimits"`
+	PerHostConcurrency int                `json:"perHostConcurrency"`
+	IgnoreRobots       bool               `json:"ignoreRobots"`
+	CacheSize          int                `json:"cacheSize"`
+}
+
+func (fc FetcherConfig) fetcherConfig() fetcher.Config {
+	return fetcher.Config{
+		UserAgent:          fc.UserAgent,
+		Timeout:            time.Duration(fc.Timeout),
+		Retries:            fc.Retries,
+		RetryBackoff:       time.Duration(fc.RetryBackoff),
+		RateLimit:          fc.RateLimit,
+		RateBurst:          fc.RateBurst,
+		HostRateLimits:     fc.HostRateLimits,
+		PerHostConcurrency: fc.PerHostConcurrency,
+		IgnoreRobots:       fc.IgnoreRobots,
+		CacheSize:          fc.CacheSize,
+	}
+}
+
+// SourceConfig describes one source instance. Type selects the registered
+// source factory, Params are passed to it as is. Disabled source is
+// validated but not fetched.
+type SourceConfig struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Params   json.RawMessage `json:"params"`
+	Schedule ScheduleConfig  `json:"schedule"`
+	Disabled bool            `json:"disabled"`
+}
+
+// ScheduleConfig controls how often source is fetched. Each run is delayed
+// by random duration up to Jitter. Failed runs are retried with
+// exponentially growing interval capped by MaxBackoff.
+type ScheduleConfig struct {
+	Interval   Duration `json:"interval"`
+	Jitter     Duration `json:"jitter"`
+	MaxBackoff Duration `json:"maxBackoff"`
+}
+
+const (
+	defaultInterval   = 1 * time.Minute
+	defaultMaxBackoff = 30 * time.Minute
+)
+
+func (sc ScheduleConfig) withDefaults() ScheduleConfig {
+	if sc.Interval == 0 {
+		sc.Interval = Duration(defaultInterval)
+	}
+	if sc.MaxBackoff == 0 {
+		sc.MaxBackoff = Duration(defaultMaxBackoff)
+	}
+	if sc.MaxBackoff < sc.Interval {
+		sc.MaxBackoff = sc.Interval
+	}
+	return sc
+}
+
+func (sc ScheduleConfig) validate() error {
+	switch {
+	case sc.Interval < 0:
+		return errors.New("negative interval")
+	case sc.Jitter < 0:
+		return errors.New("negative jitter")
+	case sc.MaxBackoff < 0:
+		return errors.New("negative max backoff")
+	}
+	return nil
+}
+
+// Duration is time.Duration which is encoded in JSON as string like "5m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return errors.New("duration should be a string: " + err.Error())
+	}
+
+	pd, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("failed to parse duration: " + err.Error())
+	}
+
+	*d = Duration(pd)
+
+	return nil
+}
+
+// DefaultConfig is used when no config file is given, it keeps lenta.ru
+// as the only source.
+func DefaultConfig() Config {
+	return Config{
+		Sources: []SourceConfig{{
+			Name: lentaru.SourceName,
+			Type: "lentaru",
+		}},
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.New("failed to read config file: " +
+			err.Error())
+	}
+
+	var c Config
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	err = d.Decode(&c)
+	if err != nil {
+		return Config{}, errors.New("failed to decode config: " + err.Error())
+	}
+
+	return c, nil
+}
@@ -0,0 +1,30 @@
+package newsaggregator
+
+import "testing"
+
+func TestIngestConfig_validate(t *testing.T) {
+	scs := []SourceConfig{{Name: "lenta.ru", Type: "lentaru"}}
+
+	tests := []struct {
+		token, name string
+		valid       bool
+	}{
+		{"", "partner-wire", false},
+		{"change-me-partner-token", "partner-wire", false},
+		{"CHANGEME0123456789", "partner-wire", false},
+		{"short", "partner-wire", false},
+		{"9f2c4e7a1b3d5f6e8a0c", "", false},
+		{"9f2c4e7a1b3d5f6e8a0c", "lenta.ru", false},
+		{"9f2c4e7a1b3d5f6e8a0c", "partner-wire", true},
+	}
+
+	for _, test := range tests {
+		err := IngestConfig{
+			Tokens: map[string]string{test.token: test.name},
+		}.validate(scs)
+		if (err == nil) != test.valid {
+			t.Errorf("token %q of %q: expected valid %v, got error %v",
+				test.token, test.name, test.valid, err)
+		}
+	}
+}
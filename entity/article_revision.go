@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// ArticleRevision is a version of article header and text. Revision 0 is
+// the originally fetched article, its RevisedAt is the article publish
+// time. Revisions belong to article by source name and canonical URL.
+type ArticleRevision struct {
+	URL          string    `json:"url" bson:"url"`
+	CanonicalURL string    `json:"canonicalURL" bson:"canonicalURL"`
+	SourceName   string    `json:"sourceName" bson:"sourceName"`
+	Number       int       `json:"number" bson:"number"`
+	Header       string    `json:"header" bson:"header"`
+	Text         string    `json:"text" bson:"text"`
+	ContentHash  string    `json:"contentHash" bson:"contentHash"`
+	PublishedAt  time.Time `json:"publishedAt" bson:"publishedAt"`
+	RevisedAt    time.Time `json:"revisedAt" bson:"revisedAt"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// BackfillCheckpoint is a progress of source backfill of days from From to
+// To: all days from From to LastDay inclusive are done. Checkpoints are
+// kept per source and range.
+type BackfillCheckpoint struct {
+	SourceName string    `bson:"sourceName"`
+	From       time.Time `bson:"from"`
+	To         time.Time `bson:"to"`
+	LastDay    time.Time `bson:"lastDay"`
+	UpdatedAt  time.Time `bson:"updatedAt"`
+}
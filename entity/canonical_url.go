@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters which don't change the page they
+// are added to.
+var trackingParams = map[string]struct{}{
+	"fbclid": {},
+	"gclid":  {},
+	"yclid":  {},
+}
+
+// CanonicalURL returns article URL in a form which is the same for all
+// URLs of the same page: scheme and host are lower cased, default port,
+// fragment and tracking query parameters are removed and the rest of query
+// parameters are sorted. Not parsable URL is returned as is.
+func CanonicalURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+
+	switch {
+	case u.Scheme == "http" && strings.HasSuffix(u.Host, ":80"):
+		u.Host = strings.TrimSuffix(u.Host, ":80")
+	case u.Scheme == "https" && strings.HasSuffix(u.Host, ":443"):
+		u.Host = strings.TrimSuffix(u.Host, ":443")
+	}
+
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+	}
+
+	if u.RawQuery != "" {
+		q := u.Query()
+
+		for p := range q {
+			_, isTracking := trackingParams[p]
+			if isTracking || strings.HasPrefix(p, "utm_") {
+				q.Del(p)
+			}
+		}
+
+		u.RawQuery = q.Encode()
+	}
+
+	return u.String()
+}
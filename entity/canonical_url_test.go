@@ -0,0 +1,52 @@
+package entity
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  "https://lenta.ru/news/2019/11/20/weather/",
+			want: "https://lenta.ru/news/2019/11/20/weather/",
+		},
+		{
+			url:  " HTTPS://Lenta.RU:443/news/2019/11/20/weather/#comments",
+			want: "https://lenta.ru/news/2019/11/20/weather/",
+		},
+		{
+			url:  "http://example.com:80",
+			want: "http://example.com/",
+		},
+		{
+			url:  "http://example.com:8080/a",
+			want: "http://example.com:8080/a",
+		},
+		{
+			url: "https://example.com/a?utm_source=rss&id=2&utm_medium=x" +
+				"&fbclid=abc&b=1",
+			want: "https://example.com/a?b=1&id=2",
+		},
+		{
+			url:  "https://example.com/a?utm_source=rss",
+			want: "https://example.com/a",
+		},
+		{
+			url:  "mid:abc%40example.com",
+			want: "mid:abc%40example.com",
+		},
+		{
+			url:  "http://[::1",
+			want: "http://[::1",
+		},
+	}
+
+	for _, test := range tests {
+		got := CanonicalURL(test.url)
+		if got != test.want {
+			t.Errorf("expected canonical URL of %q to be %q, got %q",
+				test.url, test.want, got)
+		}
+	}
+}
@@ -1,13 +1,52 @@
 package entity
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
 
+// Article is a news article. Times are stored in UTC.
 type Article struct {
-	URL         string    `json:"url" bson:"url"`
+	URL string `json:"url" bson:"url"`
+	// Type is source specific kind of the article like news, column or
+	// photo gallery.
+	Type        string    `json:"type,omitempty" bson:"type,omitempty"`
 	Header      string    `json:"header" bson:"header"`
 	PublishedAt time.Time `json:"publishedAt" bson:"publishedAt"`
-	Text        string    `json:"text" bson:"text"`
-	SourceName  string    `json:"sourceName" bson:"sourceName"`
+	// PublishedAtOffset is original publish time offset east of UTC in
+	// seconds.
+	PublishedAtOffset int        `json:"publishedAtOffset,omitempty" bson:"publishedAtOffset,omitempty"`
+	ModifiedAt        *time.Time `json:"modifiedAt,omitempty" bson:"modifiedAt,omitempty"`
+	// Lead is the lead paragraph of the article.
+	Lead     string `json:"lead,omitempty" bson:"lead,omitempty"`
+	Text     string `json:"text" bson:"text"`
+	ImageURL string `json:"imageURL,omitempty" bson:"imageURL,omitempty"`
+	Author   string `json:"author,omitempty" bson:"author,omitempty"`
+	// ExtractionMethod tells how Text was extracted from the article page,
+	// it's empty when source provided the text as is.
+	ExtractionMethod string `json:"extractionMethod,omitempty" bson:"extractionMethod,omitempty"`
+	// Categories are site sections or rubrics of the article.
+	Categories []string `json:"categories,omitempty" bson:"categories,omitempty"`
+	Tags       []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	SourceName string   `json:"sourceName" bson:"sourceName"`
+	// Revisions is number of the article changes noticed after it was
+	// stored.
+	Revisions int `json:"revisions,omitempty" bson:"revisions,omitempty"`
+	// Backfilled articles were loaded by backfill, they are kept
+	// regardless of retention.
+	Backfilled bool `json:"backfilled,omitempty" bson:"backfilled,omitempty"`
+}
+
+// ContentHash returns hash of article header and text, it's used to detect
+// article changes. Header whitespace differences are ignored.
+func (a Article) ContentHash() string {
+	h := sha256.New()
+	h.Write([]byte(strings.Join(strings.Fields(a.Header), " ")))
+	h.Write([]byte{0})
+	h.Write([]byte(a.Text))
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 type ArticlesByPublishedAt []Article
@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+// FetchReport describes a single source fetch run. Stored is number of
+// newly stored articles, Updated is number of already stored articles
+// changed by the run, Duplicates is number of already stored unchanged
+// ones. Error is set when the run failed as a whole. LayoutWarnings are
+// set when selector counts of the run deviate from the previous runs,
+// which suggests that source site layout was changed.
+type FetchReport struct {
+	SourceName string         `json:"sourceName" bson:"sourceName"`
+	StartedAt  time.Time      `json:"startedAt" bson:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt" bson:"finishedAt"`
+	Seen       int            `json:"seen" bson:"seen"`
+	Stored     int            `json:"stored" bson:"stored"`
+	Updated    int            `json:"updated" bson:"updated"`
+	Duplicates int            `json:"duplicates" bson:"duplicates"`
+	Failed     int            `json:"failed" bson:"failed"`
+	Failures   []FetchFailure `json:"failures,omitempty" bson:"failures,omitempty"`
+	Error      string         `json:"error,omitempty" bson:"error,omitempty"`
+
+	SelectorCounts        []SelectorCount `json:"selectorCounts,omitempty" bson:"selectorCounts,omitempty"`
+	SuspectedLayoutChange bool            `json:"suspectedLayoutChange,omitempty" bson:"suspectedLayoutChange,omitempty"`
+	LayoutWarnings        []string        `json:"layoutWarnings,omitempty" bson:"layoutWarnings,omitempty"`
+}
+
+type FetchFailure struct {
+	URL    string `json:"url" bson:"url"`
+	Reason string `json:"reason" bson:"reason"`
+}
+
+// SelectorCount is number of HTML selector matches during a fetch run.
+// Lookups is number of documents or elements the selector was looked up
+// in.
+type SelectorCount struct {
+	Selector string `json:"selector" bson:"selector"`
+	Lookups  int    `json:"lookups" bson:"lookups"`
+	Matches  int    `json:"matches" bson:"matches"`
+}
+
+// PerLookup returns average number of matches per lookup.
+func (sc SelectorCount) PerLookup() float64 {
+	if sc.Lookups == 0 {
+		return 0
+	}
+	return float64(sc.Matches) / float64(sc.Lookups)
+}
@@ -0,0 +1,11 @@
+package entity
+
+// FetchedArticle is a single result of streaming source fetch. Cursor is
+// the source cursor to resume after this article. When Err is not nil the
+// article is failed to fetch and may contain only partial data like URL.
+// Fetched article without Err and URL just moves the cursor.
+type FetchedArticle struct {
+	Article Article
+	Cursor  string
+	Err     error
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+type SourceSchedule struct {
+	SourceName string        `json:"sourceName"`
+	Interval   time.Duration `json:"interval"`
+	Running    bool          `json:"running"`
+	LastRunAt  time.Time     `json:"lastRunAt"`
+	LastError  string        `json:"lastError,omitempty"`
+	Failures   int           `json:"failures"`
+	NextRunAt  time.Time     `json:"nextRunAt"`
+}
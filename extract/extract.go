@@ -0,0 +1,244 @@
+// Package extract finds main content of article pages which the source
+// selectors failed to match, like long reads or photo stories with their
+// own layouts. It scores blocks of the page by the amount of paragraph text
+// they contain and penalizes link heavy blocks like menus and related news
+// lists.
+package extract
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	nethtml "golang.org/x/net/html"
+)
+
+// Extraction methods recorded in entity.Article.ExtractionMethod.
+const (
+	MethodSelector = "selector"
+	MethodDensity  = "density"
+)
+
+const (
+	minParagraphLen = 25
+	classWeight     = 25
+)
+
+var (
+	unlikelyTags = "script, style, noscript, iframe, svg, form, nav, " +
+		"header, footer, aside, button, select, textarea"
+
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|main|` +
+		`news|post|story|text`)
+	negativeRe = regexp.MustCompile(`(?i)ad-|banner|comment|footer|menu|` +
+		`promo|related|share|sidebar|social|sponsor|subscribe|widget`)
+
+	spacesRe = regexp.MustCompile(`\s+`)
+)
+
+// WithFallback returns text and MethodSelector when text isn't empty.
+// Otherwise main content of doc is extracted and returned with
+// MethodDensity. Both are empty when nothing is found.
+func WithFallback(doc *goquery.Document, text string) (string, string) {
+	if strings.TrimSpace(text) != "" {
+		return text, MethodSelector
+	}
+
+	text = MainText(doc)
+	if text == "" {
+		return "", ""
+	}
+
+	return text, MethodDensity
+}
+
+// MainText returns text of the main content block of doc, paragraphs are
+// separated by newlines. Doc is modified: the unlikely content elements are
+// removed.
+func MainText(doc *goquery.Document) string {
+	doc.Find(unlikelyTags).Remove()
+
+	var (
+		scores     = map[*nethtml.Node]float64{}
+		candidates []*goquery.Selection
+	)
+
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, p *goquery.Selection) {
+		text := normalize(p.Text())
+		if len([]rune(text)) < minParagraphLen {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) +
+			math.Min(float64(len([]rune(text)))/100, 3)
+
+		parent := p.Parent()
+		grandParent := parent.Parent()
+
+		for i, c := range []*goquery.Selection{parent, grandParent} {
+			if c.Length() == 0 {
+				continue
+			}
+			n := c.Get(0)
+			if _, exists := scores[n]; !exists {
+				scores[n] = classScore(c)
+				candidates = append(candidates, c)
+			}
+			scores[n] += score / float64(i+1)
+		}
+	})
+
+	var (
+		top      *goquery.Selection
+		topScore float64
+	)
+
+	for _, c := range candidates {
+		score := scores[c.Get(0)] * (1 - linkDensity(c))
+		if top == nil || score > topScore {
+			top, topScore = c, score
+		}
+	}
+
+	if top == nil || topScore <= 0 {
+		return blockText(doc.Find("body"))
+	}
+
+	return paragraphsText(top)
+}
+
+// classScore rewards blocks with content like class or id and penalizes
+// the ones looking like page chrome.
+func classScore(sel *goquery.Selection) float64 {
+	var score float64
+
+	for _, attr := range []string{"class", "id"} {
+		v, exists := sel.Attr(attr)
+		if !exists {
+			continue
+		}
+		if negativeRe.MatchString(v) {
+			score -= classWeight
+		}
+		if positiveRe.MatchString(v) {
+			score += classWeight
+		}
+	}
+
+	return score
+}
+
+// linkDensity is part of sel text which is the text of links.
+func linkDensity(sel *goquery.Selection) float64 {
+	textLen := len([]rune(normalize(sel.Text())))
+	if textLen == 0 {
+		return 0
+	}
+
+	var linksLen int
+
+	sel.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linksLen += len([]rune(normalize(a.Text())))
+	})
+
+	return float64(linksLen) / float64(textLen)
+}
+
+// paragraphsText returns texts of sel paragraph like descendants which are
+// not link lists.
+func paragraphsText(sel *goquery.Selection) string {
+	var ps []string
+
+	sel.Find("p, pre, blockquote, h2, h3, li").Each(
+		func(_ int, p *goquery.Selection) {
+			if p.ParentsFiltered("p, pre, blockquote, li").Length() > 0 {
+				return
+			}
+
+			text := normalize(p.Text())
+			if text == "" || linkDensity(p) > 0.5 {
+				return
+			}
+
+			ps = append(ps, text)
+		})
+
+	if len(ps) == 0 {
+		return blockText(sel)
+	}
+
+	return strings.Join(ps, "\n")
+}
+
+// blockText is the last resort for pages without paragraphs: sel text is
+// split on newlines, short lines are dropped.
+func blockText(sel *goquery.Selection) string {
+	var ps []string
+
+	for _, l := range strings.Split(sel.Text(), "\n") {
+		l = normalize(l)
+		if len([]rune(l)) >= minParagraphLen {
+			ps = append(ps, l)
+		}
+	}
+
+	return strings.Join(ps, "\n")
+}
+
+func normalize(s string) string {
+	return strings.TrimSpace(spacesRe.ReplaceAllString(s, " "))
+}
+
+// HTMLText returns text of HTML fragment like feed item or API content.
+// Paragraphs are separated by newlines, text of fragment without
+// paragraphs is returned line by line.
+func HTMLText(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return strings.TrimSpace(content)
+	}
+
+	if ps := doc.Find("p"); ps.Length() > 0 {
+		return SelectionText(ps)
+	}
+
+	doc.Find("head, script, style").Remove()
+
+	var ls []string
+
+	for _, l := range strings.Split(doc.Text(), "\n") {
+		if l = normalize(l); l != "" {
+			ls = append(ls, l)
+		}
+	}
+
+	return strings.Join(ls, "\n")
+}
+
+// Header returns article header with unescaped HTML entities and
+// collapsed whitespace, so headers taken from listings and article pages
+// can be compared.
+func Header(s string) string {
+	return normalize(html.UnescapeString(s))
+}
+
+// SelectionText returns texts of the non-empty sel elements separated by
+// newlines.
+func SelectionText(sel *goquery.Selection) string {
+	var ps []string
+
+	sel.Each(func(_ int, s *goquery.Selection) {
+		p := strings.TrimSpace(html.UnescapeString(s.Text()))
+		if p != "" {
+			ps = append(ps, p)
+		}
+	})
+
+	return strings.Join(ps, "\n")
+}
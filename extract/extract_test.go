@@ -0,0 +1,71 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const longReadHTML = `<html><body>
+<header><a href="/">Главная</a> <a href="/news">Новости</a></header>
+<div class="menu">
+  <ul>
+    <li><a href="/a">Очень длинная ссылка на другой материал сайта</a></li>
+    <li><a href="/b">Ещё одна длинная ссылка на другой материал сайта</a></li>
+  </ul>
+</div>
+<div class="longread">
+  <div class="longread__body">
+    <h2>Как устроена новая станция</h2>
+    <p>Первый абзац лонгрида, в нём есть запятые, слова и достаточно текста.</p>
+    <p>Второй абзац лонгрида, тоже длинный, чтобы набрать вес у родителя.</p>
+    <p><a href="/c">Читайте также: совсем другой материал</a></p>
+    <script>var x = "не текст статьи, а скрипт на странице";</script>
+  </div>
+</div>
+<div class="related">
+  <p><a href="/d">Похожий материал с длинным заголовком про метро</a></p>
+</div>
+<footer><p>Все права защищены, перепечатка запрещена без ссылки.</p></footer>
+</body></html>`
+
+func newDoc(t *testing.T, s string) *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	return doc
+}
+
+func TestMainText(t *testing.T) {
+	got := MainText(newDoc(t, longReadHTML))
+
+	want := "Как устроена новая станция\n" +
+		"Первый абзац лонгрида, в нём есть запятые, слова и достаточно " +
+		"текста.\n" +
+		"Второй абзац лонгрида, тоже длинный, чтобы набрать вес у родителя."
+
+	if got != want {
+		t.Errorf("unexpected main text\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	text, method := WithFallback(newDoc(t, longReadHTML), "selected text")
+	if text != "selected text" || method != MethodSelector {
+		t.Errorf("expected selected text to be kept, got %q by %q",
+			text, method)
+	}
+
+	text, method = WithFallback(newDoc(t, longReadHTML), "")
+	if text == "" || method != MethodDensity {
+		t.Errorf("expected density extraction, got %q by %q", text, method)
+	}
+
+	text, method = WithFallback(newDoc(t, "<html><body></body></html>"), "")
+	if text != "" || method != "" {
+		t.Errorf("expected nothing for empty page, got %q by %q",
+			text, method)
+	}
+}
@@ -0,0 +1,78 @@
+package fetcher
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// minSniffedBytes is minimal number of non-ASCII bytes to guess single
+// byte Cyrillic encoding by letter case statistics.
+const minSniffedBytes = 16
+
+// DecodedBody returns body transcoded to UTF-8. Charset is taken from the
+// Content-Type header, byte order mark or meta tags. When they don't
+// declare it, the body is checked to be UTF-8 and otherwise is sniffed for
+// windows-1251 or KOI8-R.
+func (r *Response) DecodedBody() ([]byte, error) {
+	e, name := r.encoding()
+
+	if e == encoding.Nop || name == "utf-8" {
+		return r.Body, nil
+	}
+
+	body, err := e.NewDecoder().Bytes(r.Body)
+	if err != nil {
+		return nil, errors.New("failed to decode " + name + " body: " +
+			err.Error())
+	}
+
+	return body, nil
+}
+
+func (r *Response) encoding() (encoding.Encoding, string) {
+	var contentType string
+	if r.Header != nil {
+		contentType = r.Header.Get("Content-Type")
+	}
+
+	e, name, certain := charset.DetermineEncoding(r.Body, contentType)
+
+	// Without declaration x/net/html/charset looks at the first kilobyte
+	// only and falls back to windows-1252, which is never the case for the
+	// sites we fetch.
+	if certain || name != "windows-1252" {
+		return e, name
+	}
+
+	if utf8.Valid(r.Body) {
+		return encoding.Nop, "utf-8"
+	}
+
+	return sniffCyrillic(r.Body)
+}
+
+// sniffCyrillic guesses single byte Cyrillic encoding of body. Lowercase
+// letters prevail in texts, they are in 0xE0-0xFF range in windows-1251 and
+// in 0xC0-0xDF range in KOI8-R.
+func sniffCyrillic(body []byte) (encoding.Encoding, string) {
+	var upper, lower int
+
+	for _, b := range body {
+		switch {
+		case b >= 0xC0 && b <= 0xDF:
+			upper++
+		case b >= 0xE0:
+			lower++
+		}
+	}
+
+	if upper+lower >= minSniffedBytes && upper > lower {
+		return charmap.KOI8R, "koi8-r"
+	}
+
+	return charmap.Windows1251, "windows-1251"
+}
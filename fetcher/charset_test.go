@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestResponse_DecodedBody(t *testing.T) {
+	const text = "Синоптики пообещали москвичам аномальное тепло"
+
+	cp1251, err := charmap.Windows1251.NewEncoder().String(text)
+	if err != nil {
+		t.Fatalf("failed to encode windows-1251: %v", err)
+	}
+
+	koi8r, err := charmap.KOI8R.NewEncoder().String(text)
+	if err != nil {
+		t.Fatalf("failed to encode KOI8-R: %v", err)
+	}
+
+	// Padding pushes text out of the first kilobyte which is looked at by
+	// x/net/html/charset, so the whole body is sniffed.
+	padding := "<!--" + strings.Repeat(" ", 2048) + "-->"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"utf-8", "text/html", "<p>" + text + "</p>"},
+		{"utf-8 after padding", "text/html", padding + "<p>" + text + "</p>"},
+		{"header", "text/html; charset=windows-1251", "<p>" + cp1251 + "</p>"},
+		{"meta", "text/html", `<meta charset="koi8-r"><p>` + koi8r + "</p>"},
+		{"http-equiv meta", "", `<meta http-equiv="Content-Type" ` +
+			`content="text/html; charset=windows-1251"><p>` + cp1251 + "</p>"},
+		{"sniffed windows-1251", "text/html", padding + "<p>" + cp1251 + "</p>"},
+		{"sniffed KOI8-R", "text/html", padding + "<p>" + koi8r + "</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Response{
+				Header: http.Header{"Content-Type": {tt.contentType}},
+				Body:   []byte(tt.body),
+			}
+
+			body, err := r.DecodedBody()
+			if err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+
+			if !strings.Contains(string(body), text) {
+				t.Errorf("expected decoded body to contain %q, got %q",
+					text, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Config of fetcher. RateLimit and RateBurst are applied to each host,
+// HostRateLimits overrides RateLimit for specific hosts. Retries is number
+// of retries of failed requests, NoRetries disables them. Transport is
+// http.DefaultTransport when nil, it's replaced in tests.
+type Config struct {
+	UserAgent          string
+	Timeout            time.Duration
+	Retries            int
+	RetryBackoff       time.Duration
+	RateLimit          float64
+	RateBurst          int
+	HostRateLimits     map[string]float64
+	PerHostConcurrency int
+	IgnoreRobots       bool
+	CacheSize          int
+	MaxBodySize        int64
+	Transport          http.RoundTripper
+}
+
+const (
+	DefaultUserAgent = "news-aggregator/1.0 " +
+		"(+https://github.com/dimuls/news-aggregator)"
+
+	// NoRetries is Retries value which disables retries, since zero
+	// means default retries.
+	NoRetries = -1
+
+	defaultTimeout            = 30 * time.Second
+	defaultRetries            = 2
+	defaultRetryBackoff       = 1 * time.Second
+	defaultRateLimit          = 5
+	defaultRateBurst          = 1
+	defaultPerHostConcurrency = 4
+	defaultCacheSize          = 200
+	defaultMaxBodySize        = 10 << 20
+)
+
+func (c Config) withDefaults() Config {
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	switch c.Retries {
+	case 0:
+		c.Retries = defaultRetries
+	case NoRetries:
+		c.Retries = 0
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = defaultRetryBackoff
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = defaultRateLimit
+	}
+	if c.RateBurst == 0 {
+		c.RateBurst = defaultRateBurst
+	}
+	if c.PerHostConcurrency == 0 {
+		c.PerHostConcurrency = defaultPerHostConcurrency
+	}
+	if c.CacheSize == 0 {
+		c.CacheSize = defaultCacheSize
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.Timeout < 0:
+		return errors.New("negative timeout")
+	case c.Retries < NoRetries:
+		return errors.New("retries less than -1")
+	case c.RetryBackoff < 0:
+		return errors.New("negative retry backoff")
+	case c.RateLimit < 0:
+		return errors.New("negative rate limit")
+	case c.RateBurst < 0:
+		return errors.New("negative rate burst")
+	case c.PerHostConcurrency < 0:
+		return errors.New("negative per host concurrency")
+	case c.CacheSize < 0:
+		return errors.New("negative cache size")
+	case c.MaxBodySize < 0:
+		return errors.New("negative max body size")
+	}
+
+	for host, limit := range c.HostRateLimits {
+		if limit <= 0 {
+			return errors.New("not positive rate limit of host " + host)
+		}
+	}
+
+	return nil
+}
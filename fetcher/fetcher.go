@@ -0,0 +1,341 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+const (
+	robotsTTL      = 24 * time.Hour
+	robotsErrorTTL = 10 * time.Minute
+)
+
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// NotModified is true when server confirmed that the previously
+	// fetched body is still actual. Header and Body are taken from the
+	// cache then.
+	NotModified bool
+}
+
+// Fetcher is HTTP client shared by sources. It limits request rate and
+// concurrency per host, retries failed requests, respects robots.txt and
+// uses conditional GET for the previously fetched URLs.
+type Fetcher struct {
+	config Config
+	agent  string
+
+	http           *http.Client
+	httpNoRedirect *http.Client
+
+	hostsMx sync.Mutex
+	hosts   map[string]*host
+
+	cacheMx sync.Mutex
+	cache   map[string]cacheEntry
+
+	log *logrus.Entry
+}
+
+type host struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+
+	robotsMx        sync.Mutex
+	robots          robots
+	robotsExpiresAt time.Time
+}
+
+type cacheEntry struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+func NewFetcher(c Config) (*Fetcher, error) {
+	err := c.validate()
+	if err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+
+	c = c.withDefaults()
+
+	agent := c.UserAgent
+	if i := strings.IndexAny(agent, "/ "); i > 0 {
+		agent = agent[:i]
+	}
+
+	return &Fetcher{
+		config: c,
+		agent:  agent,
+		http: &http.Client{
+			Transport: c.Transport,
+			Timeout:   c.Timeout,
+		},
+		httpNoRedirect: &http.Client{
+			Transport: c.Transport,
+			Timeout:   c.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		hosts: map[string]*host{},
+		cache: map[string]cacheEntry{},
+		log:   logrus.WithField("subsystem", "fetcher"),
+	}, nil
+}
+
+// Get fetches URL following redirects.
+func (f *Fetcher) Get(rawURL string) (*Response, error) {
+	return f.get(f.http, rawURL)
+}
+
+// GetNoRedirect fetches URL without following redirects, redirect
+// response is returned as is.
+func (f *Fetcher) GetNoRedirect(rawURL string) (*Response, error) {
+	return f.get(f.httpNoRedirect, rawURL)
+}
+
+func (f *Fetcher) host(u *url.URL) *host {
+	f.hostsMx.Lock()
+	defer f.hostsMx.Unlock()
+
+	h, exists := f.hosts[u.Host]
+	if !exists {
+		limit, exists := f.config.HostRateLimits[u.Hostname()]
+		if !exists {
+			limit = f.config.RateLimit
+		}
+
+		h = &host{
+			limiter: rate.NewLimiter(rate.Limit(limit), f.config.RateBurst),
+			slots:   make(chan struct{}, f.config.PerHostConcurrency),
+		}
+
+		f.hosts[u.Host] = h
+	}
+
+	return h
+}
+
+func (f *Fetcher) get(c *http.Client, rawURL string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.New("failed to parse URL: " + err.Error())
+	}
+
+	if !u.IsAbs() {
+		return nil, errors.New("URL is not absolute")
+	}
+
+	h := f.host(u)
+
+	if !f.config.IgnoreRobots && !f.robotsAllowed(h, u) {
+		return nil, ErrDisallowedByRobots
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := f.do(c, h, u.String(), true)
+		if !retriable(res, err) || attempt >= f.config.Retries {
+			return res, err
+		}
+
+		log := f.log.WithFields(logrus.Fields{
+			"url":     u.String(),
+			"attempt": attempt + 1,
+		})
+		if err != nil {
+			log = log.WithError(err)
+		} else {
+			log = log.WithField("status_code", res.StatusCode)
+		}
+		log.Warning("request failed, retrying")
+
+		time.Sleep(f.config.RetryBackoff << uint(attempt))
+	}
+}
+
+func retriable(res *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests ||
+		res.StatusCode >= 500
+}
+
+func (f *Fetcher) do(c *http.Client, h *host, rawURL string,
+	useCache bool) (*Response, error) {
+
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	err := h.limiter.Wait(context.Background())
+	if err != nil {
+		return nil, errors.New("failed to wait rate limiter: " + err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.New("failed to create request: " + err.Error())
+	}
+
+	req.Header.Set("User-Agent", f.config.UserAgent)
+
+	var (
+		ce     cacheEntry
+		cached bool
+	)
+
+	if useCache {
+		ce, cached = f.cached(rawURL)
+		if cached {
+			if ce.etag != "" {
+				req.Header.Set("If-None-Match", ce.etag)
+			}
+			if ce.lastModified != "" {
+				req.Header.Set("If-Modified-Since", ce.lastModified)
+			}
+		}
+	}
+
+	hres, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer hres.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(hres.Body,
+		f.config.MaxBodySize+1))
+	if err != nil {
+		return nil, errors.New("failed to read body: " + err.Error())
+	}
+
+	if int64(len(body)) > f.config.MaxBodySize {
+		return nil, fmt.Errorf("body is larger than %d bytes",
+			f.config.MaxBodySize)
+	}
+
+	if cached && hres.StatusCode == http.StatusNotModified {
+		return &Response{
+			URL:         rawURL,
+			StatusCode:  http.StatusOK,
+			Header:      ce.header,
+			Body:        ce.body,
+			NotModified: true,
+		}, nil
+	}
+
+	if useCache && hres.StatusCode == http.StatusOK {
+		f.store(rawURL, hres.Header, body)
+	}
+
+	return &Response{
+		URL:        rawURL,
+		StatusCode: hres.StatusCode,
+		Header:     hres.Header,
+		Body:       body,
+	}, nil
+}
+
+func (f *Fetcher) cached(rawURL string) (cacheEntry, bool) {
+	f.cacheMx.Lock()
+	defer f.cacheMx.Unlock()
+	ce, exists := f.cache[rawURL]
+	return ce, exists
+}
+
+func (f *Fetcher) store(rawURL string, header http.Header, body []byte) {
+	ce := cacheEntry{
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+		header:       header,
+		body:         body,
+	}
+
+	f.cacheMx.Lock()
+	defer f.cacheMx.Unlock()
+
+	if ce.etag == "" && ce.lastModified == "" {
+		delete(f.cache, rawURL)
+		return
+	}
+
+	if _, exists := f.cache[rawURL]; !exists &&
+		len(f.cache) >= f.config.CacheSize {
+		for u := range f.cache {
+			delete(f.cache, u)
+			break
+		}
+	}
+
+	f.cache[rawURL] = ce
+}
+
+func (f *Fetcher) robotsAllowed(h *host, u *url.URL) bool {
+	h.robotsMx.Lock()
+	defer h.robotsMx.Unlock()
+
+	now := time.Now()
+
+	if now.After(h.robotsExpiresAt) {
+		h.robots, h.robotsExpiresAt = f.fetchRobots(h, u, now)
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return h.robots.allowed(path)
+}
+
+// fetchRobots fetches robots.txt of URL host. Absent robots.txt allows
+// everything, as do the failed fetches which are retried sooner.
+func (f *Fetcher) fetchRobots(h *host, u *url.URL, now time.Time) (
+	robots, time.Time) {
+
+	rURL := u.Scheme + "://" + u.Host + "/robots.txt"
+
+	log := f.log.WithField("robots_url", rURL)
+
+	res, err := f.do(f.http, h, rURL, false)
+	if err != nil {
+		log.WithError(err).Warning("failed to get robots.txt")
+		return robots{}, now.Add(robotsErrorTTL)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return parseRobots(bytes.NewReader(res.Body), f.agent),
+			now.Add(robotsTTL)
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return robots{}, now.Add(robotsTTL)
+	default:
+		log.WithField("status_code", res.StatusCode).
+			Warning("get robots.txt returned unexpected status code")
+		return robots{}, now.Add(robotsErrorTTL)
+	}
+}
@@ -0,0 +1,185 @@
+package fetcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTransport serves responses of handle and counts requests by path.
+type testTransport struct {
+	handle func(req *http.Request) (int, http.Header, string)
+
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func (tt *testTransport) RoundTrip(req *http.Request) (*http.Response,
+	error) {
+
+	tt.mutex.Lock()
+	if tt.requests == nil {
+		tt.requests = map[string]int{}
+	}
+	tt.requests[req.URL.Path]++
+	tt.mutex.Unlock()
+
+	status, header, body := tt.handle(req)
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func (tt *testTransport) count(path string) int {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	return tt.requests[path]
+}
+
+func newTestFetcher(t *testing.T, c Config) *Fetcher {
+	c.RateLimit = 100
+	c.RetryBackoff = time.Millisecond
+
+	f, err := NewFetcher(c)
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	return f
+}
+
+func TestFetcher_Get_Retries(t *testing.T) {
+	tests := []struct {
+		retries  int
+		requests int
+	}{
+		{0, 1 + defaultRetries},
+		{1, 2},
+		{NoRetries, 1},
+	}
+
+	for _, test := range tests {
+		tt := &testTransport{
+			handle: func(*http.Request) (int, http.Header, string) {
+				return http.StatusServiceUnavailable, nil, ""
+			},
+		}
+
+		f := newTestFetcher(t, Config{
+			Retries:      test.retries,
+			IgnoreRobots: true,
+			Transport:    tt,
+		})
+
+		res, err := f.Get("https://example.com/news")
+		if err != nil {
+			t.Fatalf("retries %d: failed to get: %v", test.retries, err)
+		}
+
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("retries %d: unexpected status code %d",
+				test.retries, res.StatusCode)
+		}
+
+		if n := tt.count("/news"); n != test.requests {
+			t.Errorf("retries %d: expected %d requests, got %d",
+				test.retries, test.requests, n)
+		}
+	}
+
+	_, err := NewFetcher(Config{Retries: -2})
+	if err == nil {
+		t.Error("expected error for retries less than -1")
+	}
+}
+
+func TestFetcher_Get_Robots(t *testing.T) {
+	tt := &testTransport{
+		handle: func(req *http.Request) (int, http.Header, string) {
+			switch req.URL.Host + req.URL.Path {
+			case "example.com/robots.txt":
+				return http.StatusOK, nil,
+					"User-agent: *\nDisallow: /private/\n"
+			case "other.example.com/robots.txt":
+				return http.StatusNotFound, nil, ""
+			default:
+				return http.StatusOK, nil, "page"
+			}
+		},
+	}
+
+	f := newTestFetcher(t, Config{Transport: tt})
+
+	_, err := f.Get("https://example.com/private/page")
+	if err != ErrDisallowedByRobots {
+		t.Errorf("expected disallowed by robots error, got %v", err)
+	}
+
+	res, err := f.Get("https://example.com/news")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("expected allowed page, got %v", err)
+	}
+
+	// Absent robots.txt allows everything.
+	res, err = f.Get("https://other.example.com/private/page")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("expected allowed page, got %v", err)
+	}
+
+	if n := tt.count("/robots.txt"); n != 2 {
+		t.Errorf("expected robots.txt fetched once per host, got %d", n)
+	}
+}
+
+func TestFetcher_Get_NotModified(t *testing.T) {
+	tt := &testTransport{
+		handle: func(req *http.Request) (int, http.Header, string) {
+			if req.Header.Get("If-None-Match") == `"v1"` &&
+				req.Header.Get("If-Modified-Since") ==
+					"Wed, 20 Nov 2019 09:00:00 GMT" {
+				return http.StatusNotModified, nil, ""
+			}
+			return http.StatusOK, http.Header{
+				"Etag":          {`"v1"`},
+				"Last-Modified": {"Wed, 20 Nov 2019 09:00:00 GMT"},
+				"Content-Type":  {"text/html"},
+			}, "page"
+		},
+	}
+
+	f := newTestFetcher(t, Config{IgnoreRobots: true, Transport: tt})
+
+	res, err := f.Get("https://example.com/news")
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+
+	if res.NotModified || string(res.Body) != "page" {
+		t.Errorf("unexpected first response %+v", res)
+	}
+
+	res, err = f.Get("https://example.com/news")
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+
+	if !res.NotModified || res.StatusCode != http.StatusOK ||
+		string(res.Body) != "page" ||
+		res.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("expected cached response, got %+v", res)
+	}
+
+	if n := tt.count("/news"); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
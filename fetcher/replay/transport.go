@@ -0,0 +1,193 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+type Mode int
+
+const (
+	// Replay mode serves requests from the cassette file only.
+	Replay Mode = iota
+	// Record mode passes requests to the real transport and records
+	// responses, Save writes them to the cassette file.
+	Record
+)
+
+type interaction struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"bodyBase64,omitempty"`
+}
+
+func (i interaction) body() []byte {
+	if i.BodyBase64 != nil {
+		return i.BodyBase64
+	}
+	return []byte(i.Body)
+}
+
+// Transport is http.RoundTripper which records HTTP interactions to a
+// cassette file or replays them from it. Replayed responses of the same
+// request are served in the recorded order, the last one is repeated.
+type Transport struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mutex        sync.Mutex
+	interactions []interaction
+	served       map[string]int
+}
+
+func NewTransport(path string, mode Mode) (*Transport, error) {
+	t := &Transport{
+		mode:   mode,
+		path:   path,
+		next:   http.DefaultTransport,
+		served: map[string]int{},
+	}
+
+	if mode == Record {
+		return t, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("failed to read cassette: " + err.Error())
+	}
+
+	err = json.Unmarshal(data, &t.interactions)
+	if err != nil {
+		return nil, errors.New("failed to decode cassette: " + err.Error())
+	}
+
+	return t, nil
+}
+
+func key(method, url string) string {
+	return method + " " + url
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == Record {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.New("failed to read body: " + err.Error())
+	}
+
+	i := interaction{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+	}
+
+	if utf8.Valid(body) {
+		i.Body = string(body)
+	} else {
+		i.BodyBase64 = body
+	}
+
+	t.mutex.Lock()
+	t.interactions = append(t.interactions, i)
+	t.mutex.Unlock()
+
+	return response(req, i), nil
+}
+
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	k := key(req.Method, req.URL.String())
+
+	var matched []interaction
+
+	for _, i := range t.interactions {
+		if key(i.Method, i.URL) == k {
+			matched = append(matched, i)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s", k)
+	}
+
+	n := t.served[k]
+	if n >= len(matched) {
+		n = len(matched) - 1
+	}
+
+	t.served[k]++
+
+	return response(req, matched[n]), nil
+}
+
+func response(req *http.Request, i interaction) *http.Response {
+	body := i.body()
+	return &http.Response{
+		Status: fmt.Sprintf("%d %s", i.StatusCode,
+			http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Save writes recorded interactions to the cassette file. It does nothing
+// in replay mode.
+func (t *Transport) Save() error {
+	if t.mode != Record {
+		return nil
+	}
+
+	t.mutex.Lock()
+	data, err := json.MarshalIndent(t.interactions, "", "  ")
+	t.mutex.Unlock()
+	if err != nil {
+		return errors.New("failed to encode cassette: " + err.Error())
+	}
+
+	err = os.MkdirAll(filepath.Dir(t.path), 0755)
+	if err != nil {
+		return errors.New("failed to create cassette dir: " + err.Error())
+	}
+
+	err = ioutil.WriteFile(t.path, data, 0644)
+	if err != nil {
+		return errors.New("failed to write cassette: " + err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package fetcher
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+type robotsRule struct {
+	length  int
+	allow   bool
+	pattern *regexp.Regexp
+}
+
+// robots are robots.txt rules which apply to the fetcher user agent.
+type robots struct {
+	rules []robotsRule
+}
+
+func (r robots) allowed(path string) bool {
+	var (
+		matched bool
+		best    robotsRule
+	)
+
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if !matched || rule.length > best.length ||
+			(rule.length == best.length && rule.allow) {
+			best = rule
+			matched = true
+		}
+	}
+
+	return !matched || best.allow
+}
+
+// parseRobots parses robots.txt and returns rules of the group matching
+// agent or of the `*` group if none matches.
+func parseRobots(r io.Reader, agent string) robots {
+	agent = strings.ToLower(agent)
+
+	var (
+		own, common   []robotsRule
+		ownFound      bool
+		groupAgents   []string
+		groupHasRules bool
+		inOwn, inAny  bool
+		scanner       = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "user-agent":
+			if groupHasRules {
+				groupAgents = nil
+				groupHasRules = false
+			}
+
+			groupAgents = append(groupAgents, strings.ToLower(value))
+
+			inOwn, inAny = false, false
+
+			for _, ga := range groupAgents {
+				if ga == "*" {
+					inAny = true
+				} else if strings.Contains(agent, ga) {
+					inOwn = true
+				}
+			}
+
+			if inOwn {
+				ownFound = true
+			}
+
+		case "allow", "disallow":
+			groupHasRules = true
+
+			if value == "" {
+				continue
+			}
+
+			rule := robotsRule{
+				length:  len(value),
+				allow:   key == "allow",
+				pattern: robotsPattern(value),
+			}
+
+			if inOwn {
+				own = append(own, rule)
+			} else if inAny {
+				common = append(common, rule)
+			}
+		}
+	}
+
+	if ownFound {
+		return robots{rules: own}
+	}
+
+	return robots{rules: common}
+}
+
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	if anchored {
+		p = strings.TrimSuffix(p, "$")
+	}
+
+	expr := "^" + strings.Replace(regexp.QuoteMeta(p), `\*`, ".*", -1)
+
+	if anchored {
+		expr += "$"
+	}
+
+	return regexp.MustCompile(expr)
+}
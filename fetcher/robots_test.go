@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"strings"
+	"testing"
+)
+
+const robotsTxt = `# Comments are ignored.
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+
+User-agent: OtherBot
+Disallow: /
+
+User-agent: Googlebot
+User-agent: news-aggregator
+Disallow: /search
+Disallow: /*.pdf$
+Allow: /search/about
+Disallow:
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		agent   string
+		path    string
+		allowed bool
+	}{
+		// Own group is used, the common one is ignored.
+		{"news-aggregator", "/private/page", true},
+		{"news-aggregator", "/search?q=news", false},
+		{"news-aggregator", "/search/about", true},
+		{"news-aggregator", "/files/report.pdf", false},
+		{"news-aggregator", "/files/report.pdf?download=1", true},
+		{"news-aggregator", "/", true},
+
+		// Agent without own group gets the common one.
+		{"somebot", "/private/page", false},
+		{"somebot", "/private/public", true},
+		{"somebot", "/private/public/more", false},
+		{"somebot", "/search", true},
+
+		{"otherbot", "/anything", false},
+	}
+
+	for _, test := range tests {
+		r := parseRobots(strings.NewReader(robotsTxt), test.agent)
+
+		if allowed := r.allowed(test.path); allowed != test.allowed {
+			t.Errorf("%s %s: expected allowed %v, got %v", test.agent,
+				test.path, test.allowed, allowed)
+		}
+	}
+}
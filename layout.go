@@ -0,0 +1,123 @@
+package newsaggregator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// SelectorCounter is a source which counts matches of its HTML selectors.
+// Counts are compared with the previous runs to detect source site layout
+// changes.
+type SelectorCounter interface {
+	Source
+
+	// SelectorCounts returns counts of selector matches since the
+	// previous call.
+	SelectorCounts() []entity.SelectorCount
+}
+
+const (
+	layoutHistorySize = 20
+	layoutMinHistory  = 5
+	layoutSigmas      = 3
+	layoutMaxRatio    = 4
+)
+
+func selectorCounts(s Source) []entity.SelectorCount {
+	sc, isSelectorCounter := s.(SelectorCounter)
+	if !isSelectorCounter {
+		return nil
+	}
+	return sc.SelectorCounts()
+}
+
+// checkLayout compares selector counts of r with the previous healthy
+// source fetch reports and flags r when they deviate. Flagged reports
+// don't become the baseline, so r stays flagged until the layout recovers.
+func (na *NewsAggregator) checkLayout(r *entity.FetchReport) {
+	if len(r.SelectorCounts) == 0 {
+		return
+	}
+
+	history, err := na.store.HealthyFetchReports(r.SourceName,
+		layoutHistorySize)
+	if err != nil {
+		na.log.WithError(err).WithField("source_name", r.SourceName).
+			Error("failed to get fetch reports from store")
+		return
+	}
+
+	r.LayoutWarnings = layoutWarnings(r.SelectorCounts, history)
+	r.SuspectedLayoutChange = len(r.LayoutWarnings) > 0
+}
+
+// layoutWarnings returns warnings about selectors which matched nothing
+// while matched something before or which average matches per lookup
+// deviate sharply from history: more than layoutSigmas standard deviations
+// and more than layoutMaxRatio times. History reports with suspected layout
+// change are ignored.
+func layoutWarnings(scs []entity.SelectorCount,
+	history []entity.FetchReport) []string {
+
+	past := map[string][]float64{}
+
+	for _, r := range history {
+		if r.SuspectedLayoutChange {
+			continue
+		}
+		for _, sc := range r.SelectorCounts {
+			if sc.Lookups > 0 {
+				past[sc.Selector] = append(past[sc.Selector], sc.PerLookup())
+			}
+		}
+	}
+
+	var warnings []string
+
+	for _, sc := range scs {
+		ps := past[sc.Selector]
+
+		if sc.Lookups == 0 || len(ps) < layoutMinHistory {
+			continue
+		}
+
+		mean, std := meanStd(ps)
+		v := sc.PerLookup()
+
+		switch {
+		case v == 0 && mean > 0:
+			warnings = append(warnings, fmt.Sprintf(
+				"selector `%s` matched nothing in %d lookups, "+
+					"%.1f matches per lookup before",
+				sc.Selector, sc.Lookups, mean))
+
+		case math.Abs(v-mean) > layoutSigmas*std &&
+			(v*layoutMaxRatio < mean || v > mean*layoutMaxRatio):
+			warnings = append(warnings, fmt.Sprintf(
+				"selector `%s` matched %.1f times per lookup, "+
+					"%.1f before", sc.Selector, v, mean))
+		}
+	}
+
+	return warnings
+}
+
+func meanStd(vs []float64) (float64, float64) {
+	var sum float64
+
+	for _, v := range vs {
+		sum += v
+	}
+
+	mean := sum / float64(len(vs))
+
+	var sqSum float64
+
+	for _, v := range vs {
+		sqSum += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(sqSum / float64(len(vs)))
+}
@@ -0,0 +1,97 @@
+package newsaggregator
+
+import (
+	"testing"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+func report(suspected bool, lookups, matches int) entity.FetchReport {
+	return entity.FetchReport{
+		SelectorCounts: []entity.SelectorCount{{
+			Selector: ".item",
+			Lookups:  lookups,
+			Matches:  matches,
+		}},
+		SuspectedLayoutChange: suspected,
+	}
+}
+
+func history(n int, suspected bool, lookups, matches int) []entity.FetchReport {
+	var rs []entity.FetchReport
+	for i := 0; i < n; i++ {
+		rs = append(rs, report(suspected, lookups, matches))
+	}
+	return rs
+}
+
+func TestMeanStd(t *testing.T) {
+	mean, std := meanStd([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if mean != 5 || std != 2 {
+		t.Errorf("expected mean 5 and std 2, got %v and %v", mean, std)
+	}
+
+	mean, std = meanStd([]float64{3})
+	if mean != 3 || std != 0 {
+		t.Errorf("expected mean 3 and std 0, got %v and %v", mean, std)
+	}
+}
+
+func TestLayoutWarnings(t *testing.T) {
+	healthy := history(layoutMinHistory, false, 10, 200)
+
+	for _, c := range []struct {
+		name     string
+		scs      []entity.SelectorCount
+		history  []entity.FetchReport
+		warnings int
+	}{{
+		name:     "usual matches",
+		scs:      report(false, 10, 190).SelectorCounts,
+		history:  healthy,
+		warnings: 0,
+	}, {
+		name:     "zero matches",
+		scs:      report(false, 10, 0).SelectorCounts,
+		history:  healthy,
+		warnings: 1,
+	}, {
+		name:     "sharp drop",
+		scs:      report(false, 10, 20).SelectorCounts,
+		history:  healthy,
+		warnings: 1,
+	}, {
+		name:     "sharp rise",
+		scs:      report(false, 10, 1000).SelectorCounts,
+		history:  healthy,
+		warnings: 1,
+	}, {
+		name:     "no lookups",
+		scs:      report(false, 0, 0).SelectorCounts,
+		history:  healthy,
+		warnings: 0,
+	}, {
+		name:     "short history",
+		scs:      report(false, 10, 0).SelectorCounts,
+		history:  healthy[:layoutMinHistory-1],
+		warnings: 0,
+	}, {
+		// Broken runs don't become the baseline, so the warning stays
+		// until the layout recovers.
+		name:     "suspected history",
+		scs:      report(false, 10, 0).SelectorCounts,
+		history:  append(history(layoutHistorySize, true, 10, 0), healthy...),
+		warnings: 1,
+	}, {
+		name:     "only suspected history",
+		scs:      report(false, 10, 0).SelectorCounts,
+		history:  history(layoutHistorySize, true, 10, 0),
+		warnings: 0,
+	}} {
+		ws := layoutWarnings(c.scs, c.history)
+		if len(ws) != c.warnings {
+			t.Errorf("%s: expected %d warnings, got %q", c.name,
+				c.warnings, ws)
+		}
+	}
+}
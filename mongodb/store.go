@@ -3,9 +3,12 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/globalsign/mgo/bson"
+	driverbson "go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -20,9 +23,21 @@ type KeywordsExtractor interface {
 type Store struct {
 	client            *mongo.Client
 	articles          *mongo.Collection
+	cursors           *mongo.Collection
+	fetchReports      *mongo.Collection
+	backfills         *mongo.Collection
+	revisions         *mongo.Collection
 	keywordsExtractor KeywordsExtractor
+
+	sectionsMx        sync.Mutex
+	sections          []string
+	sectionsExpiresAt time.Time
 }
 
+// sectionsTTL is how long the section list is cached, new sections appear
+// in it with this delay.
+const sectionsTTL = 5 * time.Minute
+
 func NewStore(mongoURI string, ke KeywordsExtractor) (*Store, error) {
 	mc, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -41,35 +56,191 @@ func NewStore(mongoURI string, ke KeywordsExtractor) (*Store, error) {
 
 	db := mc.Database("newsAggregator")
 
-	return &Store{
+	s := &Store{
 		client:            mc,
 		articles:          db.Collection("articles"),
+		cursors:           db.Collection("cursors"),
+		fetchReports:      db.Collection("fetchReports"),
+		backfills:         db.Collection("backfills"),
+		revisions:         db.Collection("revisions"),
 		keywordsExtractor: ke,
-	}, nil
+	}
+
+	err = s.setCanonicalURLs(s.articles)
+	if err != nil {
+		return nil, errors.New("failed to set canonical URLs: " + err.Error())
+	}
+
+	err = s.setCanonicalURLs(s.revisions)
+	if err != nil {
+		return nil, errors.New("failed to set revisions canonical URLs: " +
+			err.Error())
+	}
+
+	err = s.mergeDuplicateArticles()
+	if err != nil {
+		return nil, errors.New("failed to merge duplicate articles: " +
+			err.Error())
+	}
+
+	_, err = s.articles.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: driverbson.D{
+			{Key: "sourceName", Value: 1},
+			{Key: "canonicalURL", Value: 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{
+				"canonicalURL": bson.M{"$exists": true},
+			}),
+	})
+	if err != nil {
+		return nil, errors.New("failed to create canonical URL index: " +
+			err.Error())
+	}
+
+	_, err = s.articles.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: driverbson.D{
+			{Key: "categories", Value: 1},
+		},
+	})
+	if err != nil {
+		return nil, errors.New("failed to create categories index: " +
+			err.Error())
+	}
+
+	// Checkpoints stored before they were kept per range have no source
+	// name field and are left out.
+	_, err = s.backfills.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: driverbson.D{
+			{Key: "sourceName", Value: 1},
+			{Key: "from", Value: 1},
+			{Key: "to", Value: 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{
+				"sourceName": bson.M{"$exists": true},
+			}),
+	})
+	if err != nil {
+		return nil, errors.New("failed to create backfills index: " +
+			err.Error())
+	}
+
+	_, err = s.revisions.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: driverbson.D{
+			{Key: "sourceName", Value: 1},
+			{Key: "canonicalURL", Value: 1},
+			{Key: "number", Value: 1},
+		},
+	})
+	if err != nil {
+		return nil, errors.New("failed to create revisions index: " +
+			err.Error())
+	}
+
+	return s, nil
 }
 
 type articleWithKeywords struct {
 	entity.Article `bson:",inline"`
+	CanonicalURL   string   `bson:"canonicalURL"`
 	Keywords       []string `bson:"keywords"`
 }
 
-func (s *Store) AddArticles(as []entity.Article) error {
+// setCanonicalURLs sets canonical URLs of articles or revisions stored in
+// c without them.
+func (s *Store) setCanonicalURLs(c *mongo.Collection) error {
+	res, err := c.Find(context.TODO(), bson.M{
+		"canonicalURL": bson.M{"$exists": false},
+	}, options.Find().SetProjection(bson.M{"url": 1}))
+	if err != nil {
+		return errors.New("failed to find articles: " + err.Error())
+	}
+
+	var as []struct {
+		ID  interface{} `bson:"_id"`
+		URL string      `bson:"url"`
+	}
+
+	err = res.All(context.TODO(), &as)
+	if err != nil {
+		return errors.New("failed to load articles: " + err.Error())
+	}
+
+	if len(as) == 0 {
+		return nil
+	}
+
 	var writes []mongo.WriteModel
 
 	for _, a := range as {
-		kw, err := s.keywordsExtractor.ExtractKeywords(a.Text)
-		if err != nil {
-			return errors.New("failed to extract keywords: " + err.Error())
-		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": a.ID}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"canonicalURL": entity.CanonicalURL(a.URL),
+			}}))
+	}
 
-		writes = append(writes, mongo.NewInsertOneModel().
-			SetDocument(articleWithKeywords{
-				Article:  a,
-				Keywords: kw,
-			}))
+	_, err = c.BulkWrite(context.TODO(), writes,
+		options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return errors.New("failed to bulk write to mongodb: " + err.Error())
 	}
 
-	_, err := s.articles.BulkWrite(context.TODO(), writes)
+	return nil
+}
+
+// duplicateArticles are articles stored with the same source name and
+// canonical URL.
+type duplicateArticles struct {
+	IDs       []interface{} `bson:"ids"`
+	OldestID  interface{}   `bson:"oldestID"`
+	Revisions int           `bson:"revisions"`
+}
+
+// mergeDuplicateArticles merges articles stored more than once with the
+// same source name and canonical URL, which was possible before the
+// canonical URL index existed. It's a migration which must run before the
+// index creation, otherwise the index can't be built.
+func (s *Store) mergeDuplicateArticles() error {
+	res, err := s.articles.Aggregate(context.TODO(), []bson.M{
+		{"$match": bson.M{
+			"canonicalURL": bson.M{"$exists": true},
+		}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"sourceName":   "$sourceName",
+				"canonicalURL": "$canonicalURL",
+			},
+			"ids":       bson.M{"$push": "$_id"},
+			"oldestID":  bson.M{"$min": "$_id"},
+			"revisions": bson.M{"$max": "$revisions"},
+			"count":     bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{
+			"count": bson.M{"$gt": 1},
+		}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var ds []duplicateArticles
+
+	err = res.All(context.TODO(), &ds)
+	if err != nil {
+		return errors.New("failed to load duplicates: " + err.Error())
+	}
+
+	writes := mergeWrites(ds)
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err = s.articles.BulkWrite(context.TODO(), writes,
+		options.BulkWrite().SetOrdered(false))
 	if err != nil {
 		return errors.New("failed to bulk write to mongodb: " + err.Error())
 	}
@@ -77,7 +248,191 @@ func (s *Store) AddArticles(as []entity.Article) error {
 	return nil
 }
 
-func (s *Store) FindArticles(query string) ([]entity.Article, error) {
+// mergeWrites returns writes which keep the oldest article of each
+// duplicates with the highest revisions number among them and delete the
+// rest.
+func mergeWrites(ds []duplicateArticles) []mongo.WriteModel {
+	var writes []mongo.WriteModel
+
+	for _, d := range ds {
+		var removed []interface{}
+
+		for _, id := range d.IDs {
+			if id != d.OldestID {
+				removed = append(removed, id)
+			}
+		}
+
+		if len(removed) == 0 {
+			continue
+		}
+
+		if d.Revisions > 0 {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": d.OldestID}).
+				SetUpdate(bson.M{"$set": bson.M{
+					"revisions": d.Revisions,
+				}}))
+		}
+
+		writes = append(writes, mongo.NewDeleteManyModel().
+			SetFilter(bson.M{"_id": bson.M{"$in": removed}}))
+	}
+
+	return writes
+}
+
+// AddArticlesResult is number of articles inserted, updated and left
+// unchanged by AddArticles.
+type AddArticlesResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+}
+
+const duplicateKeyErrorCode = 11000
+
+func articleKey(sourceName, canonicalURL string) string {
+	return sourceName + "\x00" + canonicalURL
+}
+
+// storedArticles returns stored versions of articles by their keys.
+func (s *Store) storedArticles(as []entity.Article) (
+	map[string]entity.Article, error) {
+
+	var sourceNames, canonicalURLs []string
+
+	for _, a := range as {
+		sourceNames = append(sourceNames, a.SourceName)
+		canonicalURLs = append(canonicalURLs, entity.CanonicalURL(a.URL))
+	}
+
+	res, err := s.articles.Find(context.TODO(), bson.M{
+		"sourceName":   bson.M{"$in": sourceNames},
+		"canonicalURL": bson.M{"$in": canonicalURLs},
+	})
+	if err != nil {
+		return nil, errors.New("failed to find articles: " + err.Error())
+	}
+
+	var stored []articleWithKeywords
+
+	err = res.All(context.TODO(), &stored)
+	if err != nil {
+		return nil, errors.New("failed to load articles: " + err.Error())
+	}
+
+	m := map[string]entity.Article{}
+
+	for _, a := range stored {
+		m[articleKey(a.SourceName, a.CanonicalURL)] = a.Article
+	}
+
+	return m, nil
+}
+
+// AddArticles upserts articles by source name and canonical URL. Stored
+// article is replaced as a whole, so fields missing in the new version are
+// cleared. Header or text changes are stored as article revisions like
+// ReviseArticle does. Writes are unordered, so conflicting concurrent
+// upserts of the same article don't abort the rest of them and are counted
+// as unchanged.
+func (s *Store) AddArticles(as []entity.Article) (AddArticlesResult, error) {
+	stored, err := s.storedArticles(as)
+	if err != nil {
+		return AddArticlesResult{}, errors.New(
+			"failed to get stored articles: " + err.Error())
+	}
+
+	var (
+		writes    []mongo.WriteModel
+		rs        []interface{}
+		revisedAt = time.Now()
+	)
+
+	for _, a := range as {
+		canonicalURL := entity.CanonicalURL(a.URL)
+		key := articleKey(a.SourceName, canonicalURL)
+
+		if old, exists := stored[key]; exists {
+			a.Revisions = old.Revisions
+			a.Backfilled = a.Backfilled || old.Backfilled
+
+			if a.ContentHash() != old.ContentHash() {
+				rs = append(rs, newRevisions(old, a, revisedAt)...)
+				a.Revisions++
+			}
+		}
+
+		stored[key] = a
+
+		kw, err := s.keywordsExtractor.ExtractKeywords(a.Text)
+		if err != nil {
+			return AddArticlesResult{}, errors.New(
+				"failed to extract keywords: " + err.Error())
+		}
+
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{
+				"sourceName":   a.SourceName,
+				"canonicalURL": canonicalURL,
+			}).
+			SetReplacement(articleWithKeywords{
+				Article:      a,
+				CanonicalURL: canonicalURL,
+				Keywords:     kw,
+			}).
+			SetUpsert(true))
+	}
+
+	res, err := s.articles.BulkWrite(context.TODO(), writes,
+		options.BulkWrite().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeyErrors(err) {
+		return AddArticlesResult{}, errors.New(
+			"failed to bulk write to mongodb: " + err.Error())
+	}
+
+	// Revisions are inserted after the articles, so a failed write
+	// doesn't leave revisions which would be inserted again on retry.
+	if len(rs) > 0 {
+		_, err = s.revisions.InsertMany(context.TODO(), rs)
+		if err != nil {
+			return AddArticlesResult{}, errors.New(
+				"failed to insert revisions: " + err.Error())
+		}
+	}
+
+	r := AddArticlesResult{
+		Inserted: int(res.UpsertedCount),
+		Updated:  int(res.ModifiedCount),
+	}
+
+	r.Unchanged = len(as) - r.Inserted - r.Updated
+
+	return r, nil
+}
+
+func onlyDuplicateKeyErrors(err error) bool {
+	bwe, isBulkWriteErr := err.(mongo.BulkWriteException)
+	if !isBulkWriteErr || bwe.WriteConcernError != nil ||
+		len(bwe.WriteErrors) == 0 {
+		return false
+	}
+
+	for _, we := range bwe.WriteErrors {
+		if we.Code != duplicateKeyErrorCode {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindArticles returns the latest articles matching query keywords. Not
+// empty section limits articles to the ones of this category.
+func (s *Store) FindArticles(query, section string) ([]entity.Article,
+	error) {
+
 	qkws, err := s.keywordsExtractor.ExtractKeywords(query)
 	if err != nil {
 		return nil, errors.New("failed to extract keywords from query: " +
@@ -90,6 +445,10 @@ func (s *Store) FindArticles(query string) ([]entity.Article, error) {
 		match["keywords"] = bson.M{"$all": qkws}
 	}
 
+	if section != "" {
+		match["categories"] = section
+	}
+
 	res, err := s.articles.Aggregate(context.TODO(), []bson.M{
 		{"$match": match},
 		{"$sort": bson.M{"publishedAt": -1}},
@@ -109,6 +468,39 @@ func (s *Store) FindArticles(query string) ([]entity.Article, error) {
 	return as, nil
 }
 
+// Sections returns all article categories sorted alphabetically. The list
+// is cached for sectionsTTL.
+func (s *Store) Sections() ([]string, error) {
+	s.sectionsMx.Lock()
+	defer s.sectionsMx.Unlock()
+
+	now := time.Now()
+
+	if now.Before(s.sectionsExpiresAt) {
+		return s.sections, nil
+	}
+
+	vs, err := s.articles.Distinct(context.TODO(), "categories", bson.M{})
+	if err != nil {
+		return nil, errors.New("failed to get distinct categories: " +
+			err.Error())
+	}
+
+	var ss []string
+
+	for _, v := range vs {
+		if c, isString := v.(string); isString && c != "" {
+			ss = append(ss, c)
+		}
+	}
+
+	sort.Strings(ss)
+
+	s.sections, s.sectionsExpiresAt = ss, now.Add(sectionsTTL)
+
+	return ss, nil
+}
+
 var ErrNotFound = errors.New("not found")
 
 func (s *Store) LatestArticle(sourceName string) (entity.Article, error) {
@@ -141,9 +533,391 @@ func (s *Store) LatestArticle(sourceName string) (entity.Article, error) {
 	return a.Article, nil
 }
 
+type sourceCursor struct {
+	SourceName string `bson:"_id"`
+	Cursor     string `bson:"cursor"`
+}
+
+func (s *Store) Cursor(sourceName string) (string, error) {
+	var c sourceCursor
+
+	err := s.cursors.FindOne(context.TODO(), bson.M{
+		"_id": sourceName,
+	}).Decode(&c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", ErrNotFound
+		}
+		return "", errors.New("failed to find cursor: " + err.Error())
+	}
+
+	return c.Cursor, nil
+}
+
+func (s *Store) SetCursor(sourceName string, cursor string) error {
+	_, err := s.cursors.ReplaceOne(context.TODO(), bson.M{
+		"_id": sourceName,
+	}, sourceCursor{
+		SourceName: sourceName,
+		Cursor:     cursor,
+	}, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.New("failed to replace cursor: " + err.Error())
+	}
+
+	return nil
+}
+
+// RemoveOldArticles removes articles published not after to except the
+// backfilled ones.
 func (s *Store) RemoveOldArticles(to time.Time) error {
 	_, err := s.articles.DeleteMany(context.TODO(), bson.M{
-		"publishedAt": bson.M{"le": to},
+		"publishedAt": bson.M{"$lte": to},
+		"backfilled":  bson.M{"$ne": true},
+	})
+	return err
+}
+
+func (s *Store) AddFetchReport(r entity.FetchReport) error {
+	_, err := s.fetchReports.InsertOne(context.TODO(), r)
+	if err != nil {
+		return errors.New("failed to insert fetch report: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) RemoveOldFetchReports(to time.Time) error {
+	_, err := s.fetchReports.DeleteMany(context.TODO(), bson.M{
+		"startedAt": bson.M{"$lte": to},
 	})
 	return err
 }
+
+// BackfillCheckpoint returns checkpoint of source backfill of days from
+// from to to.
+func (s *Store) BackfillCheckpoint(sourceName string, from, to time.Time) (
+	entity.BackfillCheckpoint, error) {
+
+	var c entity.BackfillCheckpoint
+
+	err := s.backfills.FindOne(context.TODO(), bson.M{
+		"sourceName": sourceName,
+		"from":       from,
+		"to":         to,
+	}).Decode(&c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.BackfillCheckpoint{}, ErrNotFound
+		}
+		return entity.BackfillCheckpoint{}, errors.New(
+			"failed to find backfill checkpoint: " + err.Error())
+	}
+
+	return c, nil
+}
+
+func (s *Store) SetBackfillCheckpoint(c entity.BackfillCheckpoint) error {
+	_, err := s.backfills.ReplaceOne(context.TODO(), bson.M{
+		"sourceName": c.SourceName,
+		"from":       c.From,
+		"to":         c.To,
+	}, c, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.New("failed to replace backfill checkpoint: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// HealthyFetchReports returns up to limit latest fetch reports of source
+// without suspected layout change, the latest first.
+func (s *Store) HealthyFetchReports(sourceName string, limit int) (
+	[]entity.FetchReport, error) {
+
+	res, err := s.fetchReports.Aggregate(context.TODO(), []bson.M{
+		{"$match": bson.M{
+			"sourceName":            sourceName,
+			"suspectedLayoutChange": bson.M{"$ne": true},
+		}},
+		{"$sort": bson.M{
+			"startedAt": -1,
+		}},
+		{"$limit": limit},
+	})
+	if err != nil {
+		return nil, errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var rs []entity.FetchReport
+
+	err = res.All(context.TODO(), &rs)
+	if err != nil {
+		return nil, errors.New("failed to load fetch reports: " +
+			err.Error())
+	}
+
+	return rs, nil
+}
+
+// LatestFetchReports returns the latest fetch report of each source.
+func (s *Store) LatestFetchReports() ([]entity.FetchReport, error) {
+	res, err := s.fetchReports.Aggregate(context.TODO(), []bson.M{
+		{"$sort": bson.M{
+			"startedAt": -1,
+		}},
+		{"$group": bson.M{
+			"_id":    "$sourceName",
+			"report": bson.M{"$first": "$$ROOT"},
+		}},
+		{"$replaceRoot": bson.M{
+			"newRoot": "$report",
+		}},
+	})
+	if err != nil {
+		return nil, errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var rs []entity.FetchReport
+
+	err = res.All(context.TODO(), &rs)
+	if err != nil {
+		return nil, errors.New("failed to load fetch reports: " +
+			err.Error())
+	}
+
+	return rs, nil
+}
+
+// RecentArticles returns articles of source published not before from.
+func (s *Store) RecentArticles(sourceName string, from time.Time) (
+	[]entity.Article, error) {
+
+	res, err := s.articles.Aggregate(context.TODO(), []bson.M{
+		{"$match": bson.M{
+			"sourceName":  sourceName,
+			"publishedAt": bson.M{"$gte": from},
+		}},
+		{"$sort": bson.M{
+			"publishedAt": -1,
+		}},
+	})
+	if err != nil {
+		return nil, errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var as []entity.Article
+
+	err = res.All(context.TODO(), &as)
+	if err != nil {
+		return nil, errors.New("failed to load articles: " + err.Error())
+	}
+
+	return as, nil
+}
+
+func newRevision(a entity.Article, number int,
+	revisedAt time.Time) entity.ArticleRevision {
+
+	return entity.ArticleRevision{
+		URL:          a.URL,
+		CanonicalURL: entity.CanonicalURL(a.URL),
+		SourceName:   a.SourceName,
+		Number:       number,
+		Header:       a.Header,
+		Text:         a.Text,
+		ContentHash:  a.ContentHash(),
+		PublishedAt:  a.PublishedAt,
+		RevisedAt:    revisedAt,
+	}
+}
+
+// newRevisions returns revisions to store when old article is revised.
+// The first revision is preceded by old as revision 0.
+func newRevisions(old, revised entity.Article,
+	revisedAt time.Time) []interface{} {
+
+	var rs []interface{}
+
+	if old.Revisions == 0 {
+		rs = append(rs, newRevision(old, 0, old.PublishedAt))
+	}
+
+	return append(rs, newRevision(revised, old.Revisions+1, revisedAt))
+}
+
+// ReviseArticle replaces stored article old header and text with the ones
+// of revised and stores revised as the next article revision. The first
+// revision also stores old as revision 0. Article is updated only if it
+// wasn't revised since old was loaded, and the revisions are inserted
+// after it, so a failed update doesn't leave revisions.
+func (s *Store) ReviseArticle(old, revised entity.Article,
+	revisedAt time.Time) error {
+
+	kw, err := s.keywordsExtractor.ExtractKeywords(revised.Text)
+	if err != nil {
+		return errors.New("failed to extract keywords: " + err.Error())
+	}
+
+	number := old.Revisions + 1
+
+	filter := bson.M{
+		"sourceName":   old.SourceName,
+		"canonicalURL": entity.CanonicalURL(old.URL),
+		"revisions":    old.Revisions,
+	}
+
+	if old.Revisions == 0 {
+		// Revisions are omitted while zero.
+		filter["revisions"] = bson.M{"$in": []interface{}{0, nil}}
+	}
+
+	res, err := s.articles.UpdateOne(context.TODO(), filter, bson.M{
+		"$set": bson.M{
+			"header":           revised.Header,
+			"text":             revised.Text,
+			"extractionMethod": revised.ExtractionMethod,
+			"keywords":         kw,
+			"revisions":        number,
+		},
+	})
+	if err != nil {
+		return errors.New("failed to update article: " + err.Error())
+	}
+
+	if res.MatchedCount == 0 {
+		return errors.New("article not found or revised concurrently")
+	}
+
+	_, err = s.revisions.InsertMany(context.TODO(),
+		newRevisions(old, revised, revisedAt))
+	if err != nil {
+		return errors.New("failed to insert revisions: " + err.Error())
+	}
+
+	return nil
+}
+
+// Revisions returns revisions of article with the given source name and
+// URL ordered by number. Revisions of the URL variants with the same
+// canonical URL are returned too.
+func (s *Store) Revisions(sourceName, url string) (
+	[]entity.ArticleRevision, error) {
+
+	res, err := s.revisions.Aggregate(context.TODO(), []bson.M{
+		{"$match": bson.M{
+			"sourceName":   sourceName,
+			"canonicalURL": entity.CanonicalURL(url),
+		}},
+		{"$sort": bson.M{
+			"number": 1,
+		}},
+	})
+	if err != nil {
+		return nil, errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var rs []entity.ArticleRevision
+
+	err = res.All(context.TODO(), &rs)
+	if err != nil {
+		return nil, errors.New("failed to load revisions: " + err.Error())
+	}
+
+	return rs, nil
+}
+
+// RemoveOldRevisions removes revisions of articles published not after to
+// which aren't stored anymore, so revisions of the backfilled articles
+// kept by RemoveOldArticles are kept too.
+func (s *Store) RemoveOldRevisions(to time.Time) error {
+	res, err := s.revisions.Aggregate(context.TODO(), []bson.M{
+		{"$match": bson.M{
+			"publishedAt": bson.M{"$lte": to},
+		}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"sourceName":   "$sourceName",
+				"canonicalURL": "$canonicalURL",
+			},
+		}},
+	})
+	if err != nil {
+		return errors.New("failed to aggregate: " + err.Error())
+	}
+
+	var keys []struct {
+		ID struct {
+			SourceName   string `bson:"sourceName"`
+			CanonicalURL string `bson:"canonicalURL"`
+		} `bson:"_id"`
+	}
+
+	err = res.All(context.TODO(), &keys)
+	if err != nil {
+		return errors.New("failed to load revised articles: " + err.Error())
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var sourceNames, canonicalURLs []string
+
+	for _, k := range keys {
+		sourceNames = append(sourceNames, k.ID.SourceName)
+		canonicalURLs = append(canonicalURLs, k.ID.CanonicalURL)
+	}
+
+	stored, err := s.articles.Find(context.TODO(), bson.M{
+		"sourceName":   bson.M{"$in": sourceNames},
+		"canonicalURL": bson.M{"$in": canonicalURLs},
+	}, options.Find().SetProjection(bson.M{
+		"sourceName":   1,
+		"canonicalURL": 1,
+	}))
+	if err != nil {
+		return errors.New("failed to find articles: " + err.Error())
+	}
+
+	var as []struct {
+		SourceName   string `bson:"sourceName"`
+		CanonicalURL string `bson:"canonicalURL"`
+	}
+
+	err = stored.All(context.TODO(), &as)
+	if err != nil {
+		return errors.New("failed to load articles: " + err.Error())
+	}
+
+	exists := map[string]bool{}
+
+	for _, a := range as {
+		exists[articleKey(a.SourceName, a.CanonicalURL)] = true
+	}
+
+	var writes []mongo.WriteModel
+
+	for _, k := range keys {
+		if exists[articleKey(k.ID.SourceName, k.ID.CanonicalURL)] {
+			continue
+		}
+		writes = append(writes, mongo.NewDeleteManyModel().
+			SetFilter(bson.M{
+				"sourceName":   k.ID.SourceName,
+				"canonicalURL": k.ID.CanonicalURL,
+			}))
+	}
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err = s.revisions.BulkWrite(context.TODO(), writes,
+		options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return errors.New("failed to bulk write to mongodb: " + err.Error())
+	}
+
+	return nil
+}
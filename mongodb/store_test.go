@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMergeWrites(t *testing.T) {
+	var ids []interface{}
+
+	for i := 0; i < 5; i++ {
+		ids = append(ids, primitive.NewObjectID())
+	}
+
+	writes := mergeWrites([]duplicateArticles{
+		{
+			IDs:       []interface{}{ids[1], ids[0], ids[2]},
+			OldestID:  ids[0],
+			Revisions: 3,
+		},
+		{
+			IDs:      []interface{}{ids[3], ids[4]},
+			OldestID: ids[3],
+		},
+		{
+			IDs:      []interface{}{ids[4]},
+			OldestID: ids[4],
+		},
+	})
+
+	want := []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": ids[0]}).
+			SetUpdate(bson.M{"$set": bson.M{"revisions": 3}}),
+		mongo.NewDeleteManyModel().
+			SetFilter(bson.M{"_id": bson.M{"$in": []interface{}{
+				ids[1], ids[2],
+			}}}),
+		mongo.NewDeleteManyModel().
+			SetFilter(bson.M{"_id": bson.M{"$in": []interface{}{
+				ids[4],
+			}}}),
+	}
+
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("unexpected writes:\n%#v\nwant:\n%#v", writes, want)
+	}
+}
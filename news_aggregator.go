@@ -3,32 +3,56 @@ package newsaggregator
 import (
 	"errors"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/fetcher"
 	"github.com/dimuls/news-aggregator/mongodb"
 	"github.com/dimuls/news-aggregator/mystem"
-	"github.com/dimuls/news-aggregator/sources/lentaru"
 	"github.com/dimuls/news-aggregator/web"
 )
 
+// Source fetches articles published not before from. The cursor is the
+// one returned by the previous call or empty string on the first call,
+// source returns the cursor to resume from on the next call. Aggregator
+// treats cursors as opaque strings and persists them per source.
 type Source interface {
 	Name() string
-	Articles(from time.Time) ([]entity.Article, error)
+	Articles(from time.Time, cursor string) ([]entity.Article, string, error)
 }
 
+// StreamingSource is a Source which sends articles to fas as soon as they
+// are fetched, so they can be stored before the whole fetch is done.
+// Failure of a single article is sent as FetchedArticle with Err, returned
+// error means the fetch can't be continued. StreamArticles must not close
+// fas.
+type StreamingSource interface {
+	Source
+	StreamArticles(from time.Time, cursor string,
+		fas chan<- entity.FetchedArticle) error
+}
+
+const streamBatchSize = 20
+
+// maxReportFailures limits number of failures kept in a fetch report,
+// the failed counter is kept exact.
+const maxReportFailures = 100
+
+const defaultRetention = 7 * 24 * time.Hour
+
 type NewsAggregator struct {
-	sources []Source
+	sources   []Source
+	schedules []*sourceSchedule
+	retention time.Duration
+	recrawl   RecrawlConfig
 
 	store     *mongodb.Store
 	webServer *web.Server
 
-	stop       chan struct{}
-	processing int32
-	waitGroup  sync.WaitGroup
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
 
 	log *logrus.Entry
 }
@@ -37,29 +61,63 @@ func NewNewsAggregator(
 	mongoURI string,
 	mystemBinPath string,
 	webServerBindAddr string,
+	c Config,
 ) (*NewsAggregator, error) {
 
-	ke := mystem.NewKeywordsExtractor(mystemBinPath)
+	f, err := fetcher.NewFetcher(c.Fetcher.fetcherConfig())
+	if err != nil {
+		return nil, errors.New("failed to create fetcher: " + err.Error())
+	}
 
-	s, err := mongodb.NewStore(mongoURI, ke)
+	ss, err := newSources(c.Sources, f)
 	if err != nil {
-		return nil, errors.New("failed to create mongoDB store")
+		return nil, errors.New("failed to create sources: " + err.Error())
 	}
 
-	lentaRu, err := lentaru.NewSource()
+	err = c.Ingest.validate(c.Sources)
 	if err != nil {
-		return nil, errors.New("failed to create lenta.ru source: " +
-			err.Error())
+		return nil, errors.New("invalid ingest config: " + err.Error())
+	}
+
+	err = c.Recrawl.validate()
+	if err != nil {
+		return nil, errors.New("invalid recrawl config: " + err.Error())
 	}
 
-	return &NewsAggregator{
-		sources: []Source{
-			lentaRu,
-		},
+	ke := mystem.NewKeywordsExtractor(mystemBinPath)
+
+	s, err := mongodb.NewStore(mongoURI, ke)
+	if err != nil {
+		return nil, errors.New("failed to create mongoDB store")
+	}
+
+	na := &NewsAggregator{
+		sources:   ss,
+		retention: time.Duration(c.Retention),
+		recrawl:   c.Recrawl.withDefaults(),
 		store:     s,
-		webServer: web.NewServer(webServerBindAddr, s),
 		log:       logrus.WithField("subsystem", "news_aggregator"),
-	}, nil
+	}
+
+	if na.retention <= 0 {
+		na.retention = defaultRetention
+	}
+
+	schedules := map[string]ScheduleConfig{}
+
+	for _, sc := range c.Sources {
+		schedules[sc.Name] = sc.Schedule
+	}
+
+	for _, src := range ss {
+		na.schedules = append(na.schedules,
+			newSourceSchedule(src, schedules[src.Name()]))
+	}
+
+	na.webServer = web.NewServer(webServerBindAddr, s, na, na,
+		c.Ingest.Tokens)
+
+	return na, nil
 }
 
 func (na *NewsAggregator) Start() error {
@@ -70,6 +128,14 @@ func (na *NewsAggregator) Start() error {
 		return errors.New("failed to start web server: " + err.Error())
 	}
 
+	for _, ss := range na.schedules {
+		na.waitGroup.Add(1)
+		go func(ss *sourceSchedule) {
+			defer na.waitGroup.Done()
+			na.runSchedule(ss)
+		}(ss)
+	}
+
 	na.waitGroup.Add(1)
 	go func() {
 		defer na.waitGroup.Done()
@@ -77,11 +143,7 @@ func (na *NewsAggregator) Start() error {
 		t := time.NewTicker(1 * time.Minute)
 
 		for {
-			na.waitGroup.Add(1)
-			go func() {
-				defer na.waitGroup.Done()
-				na.process()
-			}()
+			na.removeOldArticles(time.Now())
 
 			select {
 			case <-t.C:
@@ -92,6 +154,14 @@ func (na *NewsAggregator) Start() error {
 		}
 	}()
 
+	if na.recrawl.MaxAge > 0 {
+		na.waitGroup.Add(1)
+		go func() {
+			defer na.waitGroup.Done()
+			na.runRecrawl()
+		}()
+	}
+
 	return nil
 }
 
@@ -107,73 +177,262 @@ func (na *NewsAggregator) Stop() {
 	na.waitGroup.Wait()
 }
 
-func (na *NewsAggregator) process() {
-	if atomic.LoadInt32(&na.processing) == 1 {
-		na.log.Warning("already processing")
-		return
+func (na *NewsAggregator) loadNewArticles(s Source) error {
+	r := entity.FetchReport{
+		SourceName: s.Name(),
+		StartedAt:  time.Now(),
 	}
 
-	atomic.StoreInt32(&na.processing, 1)
-	defer atomic.StoreInt32(&na.processing, 0)
+	err := na.fetchNewArticles(s, &r)
+
+	r.FinishedAt = time.Now()
+	r.SelectorCounts = selectorCounts(s)
 
-	now := time.Now()
-	na.loadNewArticles(now)
-	na.removeOldArticles(now)
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	na.reportFetch(r)
+
+	return err
 }
 
-func (na *NewsAggregator) loadNewArticles(now time.Time) {
-	waitGroup := sync.WaitGroup{}
-
-	for _, s := range na.sources {
-		waitGroup.Add(1)
-		go func(s Source) {
-			defer waitGroup.Done()
-
-			log := na.log.WithField("source_name", s.Name())
-
-			var from time.Time
-
-			latestArticle, err := na.store.LatestArticle(s.Name())
-			if err != nil {
-				if err == mongodb.ErrNotFound {
-					from = now.AddDate(0, 0, -1)
-				} else {
-					log.WithError(err).Error(
-						"failed to get latest article for source from store")
-					return
-				}
-			} else {
-				from = latestArticle.PublishedAt.Add(1 * time.Minute)
-			}
+// Ingest stores articles pushed by external caller, they are upserted and
+// reported like fetched ones.
+func (na *NewsAggregator) Ingest(sourceName string, as []entity.Article) (
+	entity.FetchReport, error) {
 
-			newArticles, err := s.Articles(from)
-			if err != nil {
-				log.WithError(err).WithField("from", from).Error(
-					"failed to get new articles from source")
-				return
-			}
+	r := entity.FetchReport{
+		SourceName: sourceName,
+		StartedAt:  time.Now(),
+		Seen:       len(as),
+	}
 
-			if len(newArticles) == 0 {
-				return
-			}
+	for i := range as {
+		as[i].SourceName = sourceName
+	}
 
-			err = na.store.AddArticles(newArticles)
-			if err != nil {
-				log.WithError(err).Error(
-					"failed to add new articles to store")
-				return
+	err := na.storeArticles(sourceName, as, "", "", &r)
+
+	r.FinishedAt = time.Now()
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	na.reportFetch(r)
+
+	return r, err
+}
+
+func (na *NewsAggregator) reportFetch(r entity.FetchReport) {
+	log := na.log.WithFields(logrus.Fields{
+		"source_name": r.SourceName,
+		"seen":        r.Seen,
+		"stored":      r.Stored,
+		"updated":     r.Updated,
+		"duplicates":  r.Duplicates,
+		"failed":      r.Failed,
+		"duration":    r.FinishedAt.Sub(r.StartedAt).String(),
+	})
+
+	na.checkLayout(&r)
+
+	if r.SuspectedLayoutChange {
+		for _, w := range r.LayoutWarnings {
+			log.WithField("warning", w).Warning("suspected layout change")
+		}
+	}
+
+	const msg = "fetch finished"
+
+	if r.Error != "" || r.Failed > 0 {
+		for _, f := range r.Failures {
+			log.WithFields(logrus.Fields{
+				"article_url": f.URL,
+				"reason":      f.Reason,
+			}).Warning("article fetch failed")
+		}
+		log.Warning(msg)
+	} else {
+		log.Info(msg)
+	}
+
+	err := na.store.AddFetchReport(r)
+	if err != nil {
+		log.WithError(err).Error("failed to add fetch report to store")
+	}
+}
+
+func (na *NewsAggregator) fetchNewArticles(s Source,
+	r *entity.FetchReport) error {
+
+	log := na.log.WithField("source_name", s.Name())
+
+	from, cursor, err := na.resumePoint(s.Name(), time.Now())
+	if err != nil {
+		log.WithError(err).Error("failed to get resume point from store")
+		return errors.New("failed to get resume point: " + err.Error())
+	}
+
+	if ss, isStreaming := s.(StreamingSource); isStreaming {
+		return na.streamNewArticles(ss, from, cursor, r)
+	}
+
+	newArticles, nextCursor, err := s.Articles(from, cursor)
+	if err != nil {
+		log.WithError(err).WithField("from", from).Error(
+			"failed to get new articles from source")
+		return errors.New("failed to get new articles: " + err.Error())
+	}
+
+	r.Seen = len(newArticles)
+
+	return na.storeArticles(s.Name(), newArticles, cursor, nextCursor, r)
+}
+
+func (na *NewsAggregator) streamNewArticles(s StreamingSource,
+	from time.Time, cursor string, r *entity.FetchReport) error {
+
+	log := na.log.WithField("source_name", s.Name())
+
+	var (
+		fas        = make(chan entity.FetchedArticle)
+		errs       = make(chan error, 1)
+		batch      []entity.Article
+		nextCursor = cursor
+		storeErr   error
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cursor, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if storeErr != nil {
+			continue
+		}
+
+		nextCursor = fa.Cursor
+
+		if fa.Err == nil && fa.Article.URL == "" {
+			continue
+		}
+
+		r.Seen++
+
+		if fa.Err != nil {
+			r.Failed++
+			if len(r.Failures) < maxReportFailures {
+				r.Failures = append(r.Failures, entity.FetchFailure{
+					URL:    fa.Article.URL,
+					Reason: fa.Err.Error(),
+				})
 			}
+		} else {
+			batch = append(batch, fa.Article)
+		}
+
+		if len(batch) >= streamBatchSize {
+			storeErr = na.storeArticles(s.Name(), batch, cursor, nextCursor,
+				r)
+			batch = nil
+			cursor = nextCursor
+		}
+	}
+
+	if storeErr == nil {
+		storeErr = na.storeArticles(s.Name(), batch, cursor, nextCursor, r)
+	}
+
+	err := <-errs
+	if err != nil {
+		log.WithError(err).WithField("from", from).Error(
+			"failed to stream new articles from source")
+		return errors.New("failed to stream new articles: " + err.Error())
+	}
+
+	return storeErr
+}
+
+// storeArticles adds new articles to store and then moves source cursor
+// from cursor to nextCursor.
+func (na *NewsAggregator) storeArticles(sourceName string,
+	as []entity.Article, cursor, nextCursor string,
+	r *entity.FetchReport) error {
+
+	log := na.log.WithField("source_name", sourceName)
+
+	if len(as) > 0 {
+		normalizeTimes(as, na.sourceLocation(sourceName))
+
+		ar, err := na.store.AddArticles(as)
+		if err != nil {
+			log.WithError(err).Error(
+				"failed to add new articles to store")
+			return errors.New("failed to add new articles: " + err.Error())
+		}
 
-		}(s)
+		r.Stored += ar.Inserted
+		r.Updated += ar.Updated
+		r.Duplicates += ar.Unchanged
 	}
 
-	waitGroup.Wait()
+	if nextCursor != cursor {
+		err := na.store.SetCursor(sourceName, nextCursor)
+		if err != nil {
+			log.WithError(err).Error("failed to set cursor in store")
+			return errors.New("failed to set cursor: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// resumePoint returns from time and cursor for the next source fetch. When
+// source has no cursor yet, fetching starts from the latest stored
+// article or one day ago.
+func (na *NewsAggregator) resumePoint(sourceName string, now time.Time) (
+	time.Time, string, error) {
+
+	cursor, err := na.store.Cursor(sourceName)
+	if err == nil {
+		return now.Add(-na.retention), cursor, nil
+	}
+	if err != mongodb.ErrNotFound {
+		return time.Time{}, "", errors.New("failed to get cursor: " +
+			err.Error())
+	}
+
+	latestArticle, err := na.store.LatestArticle(sourceName)
+	if err != nil {
+		if err == mongodb.ErrNotFound {
+			return now.AddDate(0, 0, -1), "", nil
+		}
+		return time.Time{}, "", errors.New("failed to get latest article: " +
+			err.Error())
+	}
+
+	return latestArticle.PublishedAt, "", nil
 }
 
 func (na *NewsAggregator) removeOldArticles(now time.Time) {
-	err := na.store.RemoveOldArticles(now.AddDate(0, 0, -7))
+	err := na.store.RemoveOldArticles(now.Add(-na.retention))
 	if err != nil {
 		logrus.WithError(err).Error(
 			"failed to remove old articles from store")
 	}
+
+	err = na.store.RemoveOldFetchReports(now.Add(-na.retention))
+	if err != nil {
+		logrus.WithError(err).Error(
+			"failed to remove old fetch reports from store")
+	}
+
+	err = na.store.RemoveOldRevisions(now.Add(-na.retention))
+	if err != nil {
+		logrus.WithError(err).Error(
+			"failed to remove old revisions from store")
+	}
 }
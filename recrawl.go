@@ -0,0 +1,104 @@
+package newsaggregator
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// RefetchingSource is able to fetch an already stored article again, such
+// sources are recrawled to track article revisions.
+type RefetchingSource interface {
+	Source
+	RefetchArticle(a entity.Article) (entity.Article, error)
+}
+
+func (na *NewsAggregator) runRecrawl() {
+	t := time.NewTicker(time.Duration(na.recrawl.Interval))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-na.stop:
+			return
+		}
+
+		for _, s := range na.sources {
+			rs, isRefetching := s.(RefetchingSource)
+			if !isRefetching {
+				continue
+			}
+
+			if !na.recrawlSource(rs, time.Now()) {
+				return
+			}
+		}
+	}
+}
+
+// recrawlSource refetches source articles younger than recrawl max age and
+// stores the changed ones as revisions. It returns false when aggregator
+// is stopped.
+func (na *NewsAggregator) recrawlSource(s RefetchingSource,
+	now time.Time) bool {
+
+	log := na.log.WithField("source_name", s.Name())
+
+	as, err := na.store.RecentArticles(s.Name(),
+		now.Add(-time.Duration(na.recrawl.MaxAge)))
+	if err != nil {
+		log.WithError(err).Error("failed to get recent articles from store")
+		return true
+	}
+
+	var revised, failed int
+
+	for _, a := range as {
+		select {
+		case <-na.stop:
+			return false
+		default:
+		}
+
+		aLog := log.WithField("article_url", a.URL)
+
+		ra, err := s.RefetchArticle(a)
+		if err != nil {
+			failed++
+			aLog.WithError(err).Warning("failed to refetch article")
+			continue
+		}
+
+		if ra.ContentHash() == a.ContentHash() {
+			continue
+		}
+
+		if ra.Text == "" && a.Text != "" {
+			failed++
+			aLog.Warning("refetched article has no text, skipping")
+			continue
+		}
+
+		err = na.store.ReviseArticle(a, ra, time.Now())
+		if err != nil {
+			failed++
+			aLog.WithError(err).Error("failed to revise article in store")
+			continue
+		}
+
+		revised++
+
+		aLog.WithField("revision", a.Revisions+1).Info("article revised")
+	}
+
+	log.WithFields(logrus.Fields{
+		"checked": len(as),
+		"revised": revised,
+		"failed":  failed,
+	}).Info("recrawl finished")
+
+	return true
+}
@@ -0,0 +1,182 @@
+package newsaggregator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/external"
+	"github.com/dimuls/news-aggregator/sources/feed"
+	"github.com/dimuls/news-aggregator/sources/jsonapi"
+	"github.com/dimuls/news-aggregator/sources/lentaru"
+	"github.com/dimuls/news-aggregator/sources/mailbox"
+	"github.com/dimuls/news-aggregator/sources/scraper"
+	"github.com/dimuls/news-aggregator/sources/sitemap"
+	"github.com/dimuls/news-aggregator/sources/wordpress"
+)
+
+// SourceFactory creates source with the given name from its config
+// params. Sources should make HTTP requests with the given shared fetcher.
+type SourceFactory func(name string, params json.RawMessage,
+	f *fetcher.Fetcher) (Source, error)
+
+var sourceFactories = map[string]SourceFactory{
+	"lentaru": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c lentaru.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return lentaru.NewSource(name, c, f)
+	},
+	"feed": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c feed.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return feed.NewSource(name, c, f)
+	},
+	"scraper": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c scraper.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return scraper.NewSource(name, c, f)
+	},
+	"sitemap": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c sitemap.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return sitemap.NewSource(name, c, f)
+	},
+	"wordpress": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c wordpress.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return wordpress.NewSource(name, c, f)
+	},
+	"jsonapi": func(name string, params json.RawMessage,
+		f *fetcher.Fetcher) (Source, error) {
+		var c jsonapi.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return jsonapi.NewSource(name, c, f)
+	},
+	"external": func(name string, params json.RawMessage,
+		_ *fetcher.Fetcher) (Source, error) {
+		var p externalParams
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+		c := p.Config
+		c.Timeout = time.Duration(p.Timeout)
+		return external.NewSource(name, c)
+	},
+	"mailbox": func(name string, params json.RawMessage,
+		_ *fetcher.Fetcher) (Source, error) {
+		var c mailbox.Config
+		err := decodeParams(params, &c)
+		if err != nil {
+			return nil, err
+		}
+		return mailbox.NewSource(name, c)
+	},
+}
+
+// RegisterSourceFactory adds source type to the registry. It should be
+// called before NewNewsAggregator.
+func RegisterSourceFactory(sourceType string, f SourceFactory) {
+	sourceFactories[sourceType] = f
+}
+
+// externalParams are external source params with timeout in the config
+// duration format.
+type externalParams struct {
+	external.Config
+	Timeout Duration `json:"timeout"`
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(params))
+	d.DisallowUnknownFields()
+
+	err := d.Decode(v)
+	if err != nil {
+		return errors.New("failed to decode params: " + err.Error())
+	}
+
+	return nil
+}
+
+func newSources(scs []SourceConfig, f *fetcher.Fetcher) ([]Source, error) {
+	if len(scs) == 0 {
+		return nil, errors.New("no sources configured")
+	}
+
+	var (
+		ss    []Source
+		names = map[string]struct{}{}
+	)
+
+	for i, sc := range scs {
+		if sc.Name == "" {
+			return nil, fmt.Errorf("source #%d: empty name", i)
+		}
+
+		if _, exists := names[sc.Name]; exists {
+			return nil, fmt.Errorf("source `%s`: duplicate name", sc.Name)
+		}
+
+		names[sc.Name] = struct{}{}
+
+		err := sc.Schedule.validate()
+		if err != nil {
+			return nil, fmt.Errorf("source `%s`: invalid schedule: %v",
+				sc.Name, err)
+		}
+
+		factory, exists := sourceFactories[sc.Type]
+		if !exists {
+			return nil, fmt.Errorf("source `%s`: unknown type `%s`",
+				sc.Name, sc.Type)
+		}
+
+		s, err := factory(sc.Name, sc.Params, f)
+		if err != nil {
+			return nil, fmt.Errorf("source `%s`: %v", sc.Name, err)
+		}
+
+		if sc.Disabled {
+			continue
+		}
+
+		ss = append(ss, s)
+	}
+
+	if len(ss) == 0 {
+		return nil, errors.New("all sources are disabled")
+	}
+
+	return ss, nil
+}
@@ -0,0 +1,129 @@
+package newsaggregator
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+type sourceSchedule struct {
+	source Source
+	config ScheduleConfig
+
+	mutex sync.Mutex
+	state entity.SourceSchedule
+}
+
+func newSourceSchedule(s Source, c ScheduleConfig) *sourceSchedule {
+	c = c.withDefaults()
+	return &sourceSchedule{
+		source: s,
+		config: c,
+		state: entity.SourceSchedule{
+			SourceName: s.Name(),
+			Interval:   time.Duration(c.Interval),
+		},
+	}
+}
+
+func (ss *sourceSchedule) jitter() time.Duration {
+	if ss.config.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ss.config.Jitter)))
+}
+
+// delay returns duration to wait before the next run considering number
+// of consecutive failures.
+func (ss *sourceSchedule) delay(failures int) time.Duration {
+	d := time.Duration(ss.config.Interval)
+
+	for i := 0; i < failures && d < time.Duration(ss.config.MaxBackoff); i++ {
+		d *= 2
+	}
+
+	if d > time.Duration(ss.config.MaxBackoff) {
+		d = time.Duration(ss.config.MaxBackoff)
+	}
+
+	return d + ss.jitter()
+}
+
+func (ss *sourceSchedule) planned(next time.Time) {
+	ss.mutex.Lock()
+	ss.state.NextRunAt = next
+	ss.mutex.Unlock()
+}
+
+func (ss *sourceSchedule) started(now time.Time) {
+	ss.mutex.Lock()
+	ss.state.Running = true
+	ss.state.LastRunAt = now
+	ss.mutex.Unlock()
+}
+
+// finished records run result and returns delay before the next run.
+func (ss *sourceSchedule) finished(err error) time.Duration {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.state.Running = false
+
+	if err != nil {
+		ss.state.Failures++
+		ss.state.LastError = err.Error()
+	} else {
+		ss.state.Failures = 0
+		ss.state.LastError = ""
+	}
+
+	return ss.delay(ss.state.Failures)
+}
+
+func (ss *sourceSchedule) snapshot() entity.SourceSchedule {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	return ss.state
+}
+
+func (na *NewsAggregator) runSchedule(ss *sourceSchedule) {
+	d := ss.jitter()
+	t := time.NewTimer(d)
+	ss.planned(time.Now().Add(d))
+
+	for {
+		select {
+		case <-t.C:
+		case <-na.stop:
+			t.Stop()
+			return
+		}
+
+		ss.started(time.Now())
+
+		err := na.loadNewArticles(ss.source)
+
+		d = ss.finished(err)
+		ss.planned(time.Now().Add(d))
+		t.Reset(d)
+	}
+}
+
+// Schedule returns the current schedule state of all sources ordered by
+// the next planned run.
+func (na *NewsAggregator) Schedule() []entity.SourceSchedule {
+	var sss []entity.SourceSchedule
+
+	for _, ss := range na.schedules {
+		sss = append(sss, ss.snapshot())
+	}
+
+	sort.Slice(sss, func(i, j int) bool {
+		return sss[i].NextRunAt.Before(sss[j].NextRunAt)
+	})
+
+	return sss
+}
@@ -0,0 +1,196 @@
+package cursor
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// Position is a resume point of a source whose articles are ordered by
+// publication time: all articles published before PublishedAt are
+// already seen as are the ones with URLs published exactly at it. Retries
+// are failed articles which are fetched again.
+type Position struct {
+	PublishedAt time.Time `json:"publishedAt"`
+	URLs        []string  `json:"urls,omitempty"`
+	Retries     []Retry   `json:"retries,omitempty"`
+}
+
+// Retry is an article whose fetch failed Attempts times in a row.
+type Retry struct {
+	URL         string    `json:"url"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Attempts    int       `json:"attempts"`
+}
+
+// Decode decodes position from cursor. Empty cursor is decoded to zero
+// position.
+func Decode(c string) (Position, error) {
+	var p Position
+
+	if c == "" {
+		return p, nil
+	}
+
+	err := json.Unmarshal([]byte(c), &p)
+	if err != nil {
+		return Position{}, errors.New("failed to decode cursor: " +
+			err.Error())
+	}
+
+	return p, nil
+}
+
+func (p Position) Encode() string {
+	if p.PublishedAt.IsZero() && len(p.Retries) == 0 {
+		return ""
+	}
+
+	c, err := json.Marshal(p)
+	if err != nil {
+		panic("failed to encode cursor: " + err.Error())
+	}
+
+	return string(c)
+}
+
+// From returns the latest of from and position time.
+func (p Position) From(from time.Time) time.Time {
+	if p.PublishedAt.After(from) {
+		return p.PublishedAt
+	}
+	return from
+}
+
+// Seen reports whether article is already seen according to position.
+func (p Position) Seen(a entity.Article) bool {
+	if a.PublishedAt.Before(p.PublishedAt) {
+		return true
+	}
+
+	if a.PublishedAt.Equal(p.PublishedAt) {
+		for _, u := range p.URLs {
+			if u == a.URL {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Filter returns articles which are not seen yet.
+func (p Position) Filter(as []entity.Article) []entity.Article {
+	var fas []entity.Article
+
+	for _, a := range as {
+		if !p.Seen(a) {
+			fas = append(fas, a)
+		}
+	}
+
+	return fas
+}
+
+// Advance returns position after seeing the given articles.
+func (p Position) Advance(as []entity.Article) Position {
+	np := Position{
+		PublishedAt: p.PublishedAt,
+		URLs:        append([]string(nil), p.URLs...),
+	}
+
+	for _, a := range as {
+		switch {
+		case a.PublishedAt.After(np.PublishedAt):
+			np.PublishedAt = a.PublishedAt
+			np.URLs = []string{a.URL}
+		case a.PublishedAt.Equal(np.PublishedAt) && !np.Seen(a):
+			np.URLs = append(np.URLs, a.URL)
+		}
+	}
+
+	for _, r := range p.Retries {
+		if !np.Seen(entity.Article{URL: r.URL, PublishedAt: r.PublishedAt}) {
+			np.Retries = append(np.Retries, r)
+		}
+	}
+
+	return np
+}
+
+func (p Position) attempts(url string) int {
+	for _, r := range p.Retries {
+		if r.URL == url {
+			return r.Attempts
+		}
+	}
+	return 0
+}
+
+func (p Position) retry(a entity.Article, attempts int) Position {
+	np := p
+	np.Retries = []Retry{{
+		URL:         a.URL,
+		PublishedAt: a.PublishedAt,
+		Attempts:    attempts,
+	}}
+
+	for _, r := range p.Retries {
+		if r.URL != a.URL {
+			np.Retries = append(np.Retries, r)
+		}
+	}
+
+	return np
+}
+
+// MaxAttempts is number of runs a failed article is fetched in before it
+// is skipped, so a permanently broken article doesn't hold position
+// forever.
+const MaxAttempts = 3
+
+// Progress advances position over articles of a fetch run sent in the
+// publication time order. Position isn't advanced past a failed article,
+// so it's fetched again on the next run until it fails MaxAttempts times.
+type Progress struct {
+	pos    Position
+	held   bool
+	heldAt time.Time
+}
+
+func NewProgress(p Position) *Progress {
+	return &Progress{pos: p}
+}
+
+func (p *Progress) Position() Position {
+	return p.pos
+}
+
+// Fetched records successfully fetched article.
+func (p *Progress) Fetched(a entity.Article) {
+	if p.held && a.PublishedAt.After(p.heldAt) {
+		return
+	}
+	p.pos = p.pos.Advance([]entity.Article{a})
+}
+
+// Failed records article whose fetch failed.
+func (p *Progress) Failed(a entity.Article) {
+	attempts := p.pos.attempts(a.URL) + 1
+
+	if attempts >= MaxAttempts {
+		p.Fetched(a)
+		if p.pos.Seen(a) {
+			return
+		}
+	}
+
+	p.pos = p.pos.retry(a, attempts)
+
+	if !p.held || a.PublishedAt.Before(p.heldAt) {
+		p.held = true
+		p.heldAt = a.PublishedAt
+	}
+}
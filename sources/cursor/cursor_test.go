@@ -0,0 +1,96 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+func article(url string, minute int) entity.Article {
+	return entity.Article{
+		URL:         url,
+		PublishedAt: time.Date(2019, 11, 20, 12, minute, 0, 0, time.UTC),
+	}
+}
+
+func TestProgress(t *testing.T) {
+	a, b, c, d := article("a", 1), article("b", 2), article("c", 2),
+		article("d", 3)
+
+	p := NewProgress(Position{})
+
+	p.Fetched(a)
+	p.Failed(b)
+	p.Fetched(c)
+	p.Fetched(d)
+
+	pos := p.Position()
+
+	if !pos.Seen(a) || !pos.Seen(c) {
+		t.Errorf("expected articles fetched before failure to be seen")
+	}
+
+	if pos.Seen(b) {
+		t.Errorf("expected failed article not to be seen")
+	}
+
+	if pos.Seen(d) {
+		t.Errorf("expected article after failure not to be seen")
+	}
+
+	if !pos.From(time.Time{}).Equal(b.PublishedAt) {
+		t.Errorf("expected position to be held at failed article, got %v",
+			pos.From(time.Time{}))
+	}
+
+	// Cursor survives encoding between runs.
+	pos, err := Decode(pos.Encode())
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	p = NewProgress(pos)
+
+	p.Fetched(b)
+	p.Fetched(d)
+
+	pos = p.Position()
+
+	if !pos.Seen(b) || !pos.Seen(d) {
+		t.Errorf("expected retried articles to be seen")
+	}
+
+	if len(pos.Retries) != 0 {
+		t.Errorf("expected no retries left, got %v", pos.Retries)
+	}
+}
+
+func TestProgress_MaxAttempts(t *testing.T) {
+	a, b := article("a", 1), article("b", 2)
+
+	pos := Position{}
+
+	for i := 1; i <= MaxAttempts; i++ {
+		p := NewProgress(pos)
+
+		p.Failed(a)
+		p.Fetched(b)
+
+		pos = p.Position()
+
+		if i < MaxAttempts && (pos.Seen(a) || pos.Seen(b)) {
+			t.Fatalf("expected articles not to be seen after %d attempts",
+				i)
+		}
+	}
+
+	if !pos.Seen(a) || !pos.Seen(b) {
+		t.Errorf("expected failed article to be skipped after %d attempts",
+			MaxAttempts)
+	}
+
+	if len(pos.Retries) != 0 {
+		t.Errorf("expected no retries left, got %v", pos.Retries)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package external
+
+import "os/exec"
+
+// setProcessGroup does nothing, process groups are unix only.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcess kills the started cmd only, its children are left running.
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
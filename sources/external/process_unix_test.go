@@ -0,0 +1,70 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package external
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+func TestSource_StreamArticles_Timeout(t *testing.T) {
+	// Background child keeps stdout open, so the run ends in time only
+	// when the whole process group is killed.
+	s, err := NewSource("example", Config{
+		Command: "sh",
+		Args:    []string{"-c", `echo '{"cursor": "1"}'; sleep 30 & wait`},
+		Timeout: 500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	fas := make(chan entity.FetchedArticle, 10)
+	started := time.Now()
+
+	err = s.StreamArticles(time.Now(), "", fas)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("expected command to be killed on timeout, took %s",
+			elapsed)
+	}
+
+	close(fas)
+
+	if fa := <-fas; fa.Cursor != "1" {
+		t.Errorf("expected cursor record before timeout, got %+v", fa)
+	}
+}
+
+func TestSource_StreamArticles_LongRecord(t *testing.T) {
+	// Command blocked on writing the rest of too long record is killed
+	// instead of being waited for.
+	s, err := NewSource("example", Config{
+		Command: "sh",
+		Args: []string{"-c",
+			`head -c 17000000 /dev/zero | tr '\0' a; sleep 30`},
+	})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	fas := make(chan entity.FetchedArticle, 10)
+	started := time.Now()
+
+	err = s.StreamArticles(time.Now(), "", fas)
+	if err == nil || !strings.Contains(err.Error(), "failed to read stdout") {
+		t.Errorf("expected read stdout error, got %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("expected command to be killed on read error, took %s",
+			elapsed)
+	}
+}
@@ -0,0 +1,268 @@
+// Package external implements source which runs external executable to
+// fetch articles, so scrapers can be written in any language.
+//
+// The executable is started on each fetch with NEWS_AGGREGATOR_SOURCE_NAME,
+// NEWS_AGGREGATOR_FROM (RFC 3339 time) and NEWS_AGGREGATOR_CURSOR
+// environment variables. It should write newline delimited JSON records
+// to stdout. Each record is an article with the fields of entity.Article
+// and optional "cursor" field. Record with "error" field reports failure
+// of the article with optional "url", record with the "cursor" field only
+// just moves the cursor. The cursor of the last record is passed to the
+// next run. Non-zero exit code fails the fetch, stderr is included to the
+// error.
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// Config declares external source. Timeout limits the command run time,
+// 10 minutes by default, it's decoded by the caller in the config duration
+// format. On timeout the whole process group of the command is killed.
+type Config struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Timeout time.Duration     `json:"-"`
+}
+
+const (
+	defaultTimeout = 10 * time.Minute
+	maxRecordSize  = 16 << 20
+	maxStderrSize  = 4 << 10
+)
+
+type Source struct {
+	name    string
+	config  Config
+	timeout time.Duration
+	log     *logrus.Entry
+}
+
+func NewSource(name string, c Config) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	if c.Command == "" {
+		return nil, errors.New("empty command")
+	}
+
+	if c.Timeout < 0 {
+		return nil, errors.New("negative timeout")
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Source{
+		name:    name,
+		config:  c,
+		timeout: timeout,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "external_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+type record struct {
+	entity.Article
+	Cursor *string `json:"cursor"`
+	Error  string  `json:"error"`
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas      = make(chan entity.FetchedArticle)
+		errs     = make(chan error, 1)
+		as       []entity.Article
+		nextCur  = cur
+		fetchErr error
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil && fetchErr == nil {
+			fetchErr = fa.Err
+		}
+		if fa.Err == nil && fa.Article.URL != "" {
+			as = append(as, fa.Article)
+		}
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fetchErr != nil {
+		return nil, "", fetchErr
+	}
+
+	return as, nextCur, nil
+}
+
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	cmd := exec.Command(s.config.Command, s.config.Args...)
+
+	cmd.Env = append(os.Environ(),
+		"NEWS_AGGREGATOR_SOURCE_NAME="+s.name,
+		"NEWS_AGGREGATOR_FROM="+from.Format(time.RFC3339),
+		"NEWS_AGGREGATOR_CURSOR="+cur)
+
+	for k, v := range s.config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stderr := &tailBuffer{max: maxStderrSize}
+	cmd.Stderr = stderr
+
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.New("failed to get stdout pipe: " + err.Error())
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return errors.New("failed to start command: " + err.Error())
+	}
+
+	var timedOut int32
+
+	timer := time.AfterFunc(s.timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		s.kill(cmd)
+	})
+	defer timer.Stop()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), maxRecordSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		fas <- s.fetchedArticle(data, line, &cur)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		// Command may be blocked on writing the rest of stdout, so it's
+		// killed instead of being waited for.
+		s.kill(cmd)
+		cmd.Wait()
+		return errors.New("failed to read stdout: " + err.Error())
+	}
+
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		if atomic.LoadInt32(&timedOut) == 1 {
+			err = fmt.Errorf("timed out after %s", s.timeout)
+		}
+		return fmt.Errorf("command failed: %v: %s", err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// kill kills the started command with its process group where supported.
+func (s *Source) kill(cmd *exec.Cmd) {
+	err := killProcess(cmd)
+	if err != nil {
+		s.log.WithError(err).Warning("failed to kill command")
+	}
+}
+
+// fetchedArticle converts record line to fetched article. Cursor only
+// records are converted to fetched article without URL.
+func (s *Source) fetchedArticle(data []byte, line int,
+	cur *string) entity.FetchedArticle {
+
+	var r record
+
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return entity.FetchedArticle{
+			Article: entity.Article{SourceName: s.name},
+			Cursor:  *cur,
+			Err: fmt.Errorf("failed to decode record on line %d: %v",
+				line, err),
+		}
+	}
+
+	if r.Cursor != nil {
+		*cur = *r.Cursor
+	}
+
+	r.Article.SourceName = s.name
+
+	switch {
+	case r.Error != "":
+		err = errors.New(r.Error)
+	case r.Article.URL == "" && r.Cursor != nil:
+		return entity.FetchedArticle{Cursor: *cur}
+	case r.Article.URL == "":
+		err = fmt.Errorf("record on line %d has no url", line)
+	case r.Article.PublishedAt.IsZero():
+		err = fmt.Errorf("record on line %d has no publishedAt", line)
+	}
+
+	return entity.FetchedArticle{
+		Article: r.Article,
+		Cursor:  *cur,
+		Err:     err,
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.buf = append(tb.buf, p...)
+	if len(tb.buf) > tb.max {
+		tb.buf = tb.buf[len(tb.buf)-tb.max:]
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	return string(tb.buf)
+}
@@ -0,0 +1,225 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// item is a feed item. Err is set when item is malformed, such item is
+// skipped and reported as failure.
+type item struct {
+	URL         string
+	Header      string
+	PublishedAt time.Time
+	Content     string
+	Err         error
+}
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// parseFeed parses RSS or Atom feed. Times with zone abbreviation are
+// parsed in loc, so the abbreviations of loc get its offset.
+func parseFeed(r io.Reader, loc *time.Location) ([]item, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	// Feeds in windows-1251 or KOI8-R declare it in XML declaration.
+	d.CharsetReader = charset.NewReaderLabel
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("root element not found")
+			}
+			return nil, errors.New("failed to read XML token: " + err.Error())
+		}
+
+		root, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch root.Name.Local {
+		case "rss":
+			var f rssFeed
+			err = d.DecodeElement(&f, &root)
+			if err != nil {
+				return nil, errors.New("failed to decode RSS: " + err.Error())
+			}
+			return rssItems(f, loc), nil
+		case "feed":
+			var f atomFeed
+			err = d.DecodeElement(&f, &root)
+			if err != nil {
+				return nil, errors.New("failed to decode Atom: " + err.Error())
+			}
+			return atomItems(f, loc), nil
+		default:
+			return nil, errors.New("unknown feed root element: " +
+				root.Name.Local)
+		}
+	}
+}
+
+func rssItems(f rssFeed, loc *time.Location) []item {
+	var is []item
+
+	for _, ri := range f.Channel.Items {
+		link := strings.TrimSpace(ri.Link)
+		if link == "" {
+			link = strings.TrimSpace(ri.GUID)
+		}
+		if link == "" {
+			is = append(is, item{
+				Err: errors.New("failed to find RSS item link"),
+			})
+			continue
+		}
+
+		publishedAt, err := parseTime(ri.PubDate, rssTimeLayouts, loc)
+		if err != nil {
+			is = append(is, item{
+				URL: link,
+				Err: errors.New("failed to parse RSS item pubDate: " +
+					err.Error()),
+			})
+			continue
+		}
+
+		content := ri.Encoded
+		if content == "" {
+			content = ri.Description
+		}
+
+		is = append(is, item{
+			URL:         link,
+			Header:      strings.TrimSpace(ri.Title),
+			PublishedAt: publishedAt,
+			Content:     content,
+		})
+	}
+
+	return is
+}
+
+func atomItems(f atomFeed, loc *time.Location) []item {
+	var is []item
+
+	for _, ae := range f.Entries {
+		var link string
+
+		for _, l := range ae.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+		if link == "" {
+			is = append(is, item{
+				Err: errors.New("failed to find Atom entry link"),
+			})
+			continue
+		}
+
+		timeStr := ae.Published
+		if strings.TrimSpace(timeStr) == "" {
+			timeStr = ae.Updated
+		}
+
+		publishedAt, err := parseTime(timeStr, atomTimeLayouts, loc)
+		if err != nil {
+			is = append(is, item{
+				URL: link,
+				Err: errors.New("failed to parse Atom entry time: " +
+					err.Error()),
+			})
+			continue
+		}
+
+		content := ae.Content
+		if content == "" {
+			content = ae.Summary
+		}
+
+		is = append(is, item{
+			URL:         link,
+			Header:      strings.TrimSpace(ae.Title),
+			PublishedAt: publishedAt,
+			Content:     content,
+		})
+	}
+
+	return is
+}
+
+var rssTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+}
+
+var atomTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+// parseTime parses s by the first matching layout. Unknown zone
+// abbreviations get zero offset, as time.Parse does.
+func parseTime(s string, layouts []string, loc *time.Location) (
+	time.Time, error) {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errors.New("empty time")
+	}
+
+	for _, l := range layouts {
+		t, err := time.ParseInLocation(l, s, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("unknown time format: " + s)
+}
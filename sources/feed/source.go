@@ -0,0 +1,253 @@
+package feed
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+	"github.com/dimuls/news-aggregator/sources/link"
+)
+
+// Config declares a feed source. When TextSelector is not empty, article
+// text is fetched from the article page using it, otherwise it is taken
+// from the feed item content. Relative item links are resolved against
+// URL. Timezone is the feed publisher timezone, UTC by default.
+type Config struct {
+	URL          string `json:"url"`
+	TextSelector string `json:"textSelector"`
+	Timezone     string `json:"timezone"`
+}
+
+// Source is a generic RSS 2.0 or Atom feed source.
+type Source struct {
+	name         string
+	feedURL      string
+	textSelector string
+	location     *time.Location
+
+	fetcher *fetcher.Fetcher
+	log     *logrus.Entry
+}
+
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, errors.New("failed to parse feed URL: " + err.Error())
+	}
+
+	if !u.IsAbs() {
+		return nil, errors.New("feed URL is not absolute")
+	}
+
+	loc := time.UTC
+
+	if c.Timezone != "" {
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, errors.New("failed to load timezone: " + err.Error())
+		}
+	}
+
+	return &Source{
+		name:         name,
+		feedURL:      c.URL,
+		textSelector: c.TextSelector,
+		location:     loc,
+		fetcher:      f,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "feed_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.location
+}
+
+// Articles returns articles fetched by StreamArticles, failed articles are
+// logged and skipped.
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas     = make(chan entity.FetchedArticle)
+		errs    = make(chan error, 1)
+		as      []entity.Article
+		nextCur = cur
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil {
+			s.log.WithError(fa.Err).WithField("article_url", fa.Article.URL).
+				Warning("failed to fetch article, skipping")
+		} else {
+			as = append(as, fa.Article)
+		}
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	return as, nextCur, nil
+}
+
+// StreamArticles sends feed items published not before from to fas.
+// Malformed items and items which page text failed to fetch are sent as
+// failures, the cursor isn't advanced past the latter so they are retried.
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	from = pos.From(from)
+
+	is, err := s.items()
+	if err != nil {
+		return err
+	}
+
+	p := cursor.NewProgress(pos)
+
+	var as []entity.Article
+
+	for _, i := range is {
+		if i.URL != "" {
+			var err error
+			i.URL, err = link.Resolve(s.feedURL, i.URL)
+			if err != nil && i.Err == nil {
+				i.Err = err
+			}
+		}
+
+		if i.Err != nil {
+			fas <- entity.FetchedArticle{
+				Article: entity.Article{URL: i.URL, SourceName: s.name},
+				Cursor:  p.Position().Encode(),
+				Err:     i.Err,
+			}
+			continue
+		}
+
+		a := entity.Article{
+			URL:         i.URL,
+			Header:      html.UnescapeString(i.Header),
+			PublishedAt: i.PublishedAt,
+			Text:        extract.HTMLText(i.Content),
+			SourceName:  s.name,
+		}
+
+		if a.PublishedAt.Before(from) || pos.Seen(a) {
+			continue
+		}
+
+		as = append(as, a)
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	for _, a := range as {
+		var err error
+
+		if s.textSelector != "" {
+			a.Text, a.ExtractionMethod, err = s.articleText(a.URL)
+			if err != nil {
+				err = errors.New("failed to get article text: " +
+					err.Error())
+			}
+		}
+
+		if err != nil {
+			p.Failed(a)
+		} else {
+			p.Fetched(a)
+		}
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// items fetches and parses the feed.
+func (s *Source) items() ([]item, error) {
+	res, err := s.fetcher.Get(s.feedURL)
+	if err != nil {
+		return nil, errors.New("failed to HTTP get feed URL: " + err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		s.log.WithField("status_code", res.StatusCode).
+			Error("get feed returned not OK status code")
+		return nil, errors.New("not OK status code")
+	}
+
+	is, err := parseFeed(bytes.NewReader(res.Body), s.location)
+	if err != nil {
+		return nil, errors.New("failed to parse feed: " + err.Error())
+	}
+
+	return is, nil
+}
+
+func (s *Source) articleText(aURL string) (string, string, error) {
+	res, err := s.fetcher.Get(aURL)
+	if err != nil {
+		return "", "", errors.New("failed to HTTP get article URL: " +
+			err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", errors.New("not OK status code")
+	}
+
+	body, err := res.DecodedBody()
+	if err != nil {
+		return "", "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", "", errors.New("failed to parse article HTML: " +
+			err.Error())
+	}
+
+	text, method := extract.WithFallback(doc,
+		extract.SelectionText(doc.Find(s.textSelector)))
+
+	return text, method, nil
+}
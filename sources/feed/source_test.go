@@ -0,0 +1,212 @@
+package feed
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+)
+
+var from = time.Date(2019, 11, 19, 0, 0, 0, 0, time.UTC)
+
+func newTestSource(t *testing.T, c Config) *Source {
+	tr, err := replay.NewTransport(filepath.Join("testdata", "feeds.json"),
+		replay.Replay)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: true,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("example", c, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	return s
+}
+
+func stream(t *testing.T, s *Source, cur string) (
+	[]entity.FetchedArticle, string) {
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+		cur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	return res, cur
+}
+
+func checkArticle(t *testing.T, a entity.Article, url, header, text string,
+	publishedAt time.Time) {
+
+	t.Helper()
+
+	if a.URL != url || a.Header != header || a.Text != text ||
+		a.SourceName != "example" {
+		t.Errorf("unexpected article %+v", a)
+	}
+
+	if !a.PublishedAt.Equal(publishedAt) {
+		t.Errorf("%s: expected publish time %v, got %v", a.URL,
+			publishedAt, a.PublishedAt)
+	}
+}
+
+func TestSource_StreamArticles_RSS(t *testing.T) {
+	s := newTestSource(t, Config{URL: "https://news.example.com/rss.xml"})
+
+	fas, _ := stream(t, s, "")
+
+	if len(fas) != 4 {
+		t.Fatalf("expected 4 articles, got %d", len(fas))
+	}
+
+	// Malformed items are reported as failures and don't stop the feed.
+	if fas[0].Err == nil ||
+		fas[0].Article.URL != "https://news.example.com/news/bad" {
+		t.Errorf("expected failed item with bad date, got %+v", fas[0])
+	}
+
+	if fas[1].Err == nil || fas[1].Article.URL != "" {
+		t.Errorf("expected failed item without link, got %+v", fas[1])
+	}
+
+	for _, fa := range fas[2:] {
+		if fa.Err != nil {
+			t.Fatalf("failed to fetch %s: %v", fa.Article.URL, fa.Err)
+		}
+	}
+
+	checkArticle(t, fas[2].Article, "https://news.example.com/news/1",
+		"Тепло в Москве", "Синоптики обещают тепло.",
+		time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC))
+
+	checkArticle(t, fas[3].Article, "https://news.example.com/news/2",
+		`Новая станция "Лесная"`, "Открыта станция.\nВторой абзац.",
+		time.Date(2019, 11, 20, 10, 0, 0, 0, time.UTC))
+}
+
+func TestSource_Articles_Atom(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL: "https://blog.example.com/feeds/atom.xml",
+	})
+
+	as, _, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(as))
+	}
+
+	// Updated time is used when published is missing, relative links are
+	// resolved against the feed URL.
+	checkArticle(t, as[0], "https://blog.example.com/feeds/posts/2",
+		"Вторая запись", "Краткий текст второй записи.",
+		time.Date(2019, 11, 20, 8, 0, 0, 0, time.UTC))
+
+	checkArticle(t, as[1], "https://blog.example.com/posts/1",
+		"Первая запись", "Текст первой записи.",
+		time.Date(2019, 11, 20, 10, 0, 0, 0, time.UTC))
+}
+
+func TestSource_Articles_ZoneAbbreviation(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL:      "https://news.example.com/msk.xml",
+		Timezone: "Europe/Moscow",
+	})
+
+	as, _, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 1 {
+		t.Fatalf("expected 1 article, got %d", len(as))
+	}
+
+	// MSK abbreviation gets Moscow offset, not zero one.
+	checkArticle(t, as[0], "https://news.example.com/news/msk",
+		"Новость по московскому времени", "Время с аббревиатурой зоны.",
+		time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC))
+}
+
+func TestSource_StreamArticles_TextSelector(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL:          "https://news.example.com/rss.xml",
+		TextSelector: "article p",
+	})
+
+	fas, cur := stream(t, s, "")
+
+	if len(fas) != 4 {
+		t.Fatalf("expected 4 articles, got %d", len(fas))
+	}
+
+	first, second := fas[2], fas[3]
+
+	if first.Err != nil ||
+		first.Article.Text != "Синоптики обещают аномальное тепло." ||
+		first.Article.ExtractionMethod != extract.MethodSelector {
+		t.Errorf("unexpected first article %+v: %v", first.Article,
+			first.Err)
+	}
+
+	if second.Err == nil {
+		t.Errorf("expected failed second article")
+	}
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if !pos.Seen(first.Article) || pos.Seen(second.Article) {
+		t.Error("expected cursor to stop at failed article")
+	}
+
+	fas, cur = stream(t, s, cur)
+
+	if len(fas) != 3 || fas[2].Err != nil ||
+		fas[2].Article.Text != "Станция открыта для пассажиров." {
+		t.Fatalf("expected retried second article, got %+v", fas)
+	}
+
+	pos, err = cursor.Decode(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if !pos.Seen(fas[2].Article) {
+		t.Error("expected cursor to pass retried article")
+	}
+}
@@ -0,0 +1,117 @@
+package jsonapi
+
+import (
+	"errors"
+	"strings"
+)
+
+// Config declares JSON API source.
+//
+// URL is a template with placeholders: {page} and {offset} of the
+// requested page, {limit} which is the page size and {from} which is the
+// resume time formatted with DateLayout.
+//
+// Items is the path of the items array in the response, empty path means
+// the response itself is the array. Fields are the paths of the article
+// fields in an item, URL and PublishedAt are required. TextHTML makes
+// text stripped from HTML.
+//
+// DateLayout is Go time layout of publishedAt, "unix" or "unixms" for the
+// numeric timestamps, RFC 3339 by default. Dates without offset are in
+// Timezone, UTC by default.
+type Config struct {
+	URL        string           `json:"url"`
+	Items      string           `json:"items"`
+	Fields     FieldsConfig     `json:"fields"`
+	TextHTML   bool             `json:"textHTML"`
+	DateLayout string           `json:"dateLayout"`
+	Timezone   string           `json:"timezone"`
+	Pagination PaginationConfig `json:"pagination"`
+}
+
+type FieldsConfig struct {
+	URL         string `json:"url"`
+	Header      string `json:"header"`
+	PublishedAt string `json:"publishedAt"`
+	Text        string `json:"text"`
+	Author      string `json:"author"`
+}
+
+// Pagination types.
+const (
+	PaginationNone   = ""
+	PaginationPage   = "page"
+	PaginationOffset = "offset"
+	PaginationNext   = "next"
+)
+
+// PaginationConfig declares how the next page is requested: by page
+// number, by items offset or by the next page link found at Next path.
+// Paging stops on an empty page, on a page shorter than Limit, on a page
+// with all the items published before the resume time or after MaxPages
+// pages. So endpoints listing the oldest items first should be filtered by
+// {from} placeholder. When endpoint listing the newest items first is
+// stopped by MaxPages, the older items are skipped and reported as fetch
+// failure: MaxPages should be raised.
+type PaginationConfig struct {
+	Type      string `json:"type"`
+	Limit     int    `json:"limit"`
+	FirstPage int    `json:"firstPage"`
+	Next      string `json:"next"`
+	MaxPages  int    `json:"maxPages"`
+}
+
+const (
+	defaultLimit    = 50
+	defaultMaxPages = 20
+)
+
+func (c Config) validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("empty URL")
+	case c.Fields.URL == "":
+		return errors.New("empty URL field path")
+	case c.Fields.PublishedAt == "":
+		return errors.New("empty publishedAt field path")
+	}
+
+	pc := c.Pagination
+
+	switch pc.Type {
+	case PaginationNone:
+	case PaginationPage:
+		if !strings.Contains(c.URL, "{page}") {
+			return errors.New("URL has no {page} placeholder")
+		}
+	case PaginationOffset:
+		if !strings.Contains(c.URL, "{offset}") {
+			return errors.New("URL has no {offset} placeholder")
+		}
+	case PaginationNext:
+		if pc.Next == "" {
+			return errors.New("empty next link path")
+		}
+	default:
+		return errors.New("unknown pagination type `" + pc.Type + "`")
+	}
+
+	switch {
+	case pc.Limit < 0:
+		return errors.New("negative pagination limit")
+	case pc.MaxPages < 0:
+		return errors.New("negative max pages")
+	}
+
+	return nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.Pagination.Limit == 0 {
+		c.Pagination.Limit = defaultLimit
+	}
+	if c.Pagination.MaxPages == 0 {
+		c.Pagination.MaxPages = defaultMaxPages
+	}
+	return c
+}
@@ -0,0 +1,115 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a JSONPath-like path of a value in decoded JSON, like
+// `$.data.items[0].title`. Leading `$` and dot are optional, empty path is
+// the root value.
+type path []segment
+
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parsePath(s string) (path, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "$")
+	s = strings.TrimPrefix(s, ".")
+
+	if s == "" {
+		return nil, nil
+	}
+
+	var p path
+
+	for _, part := range strings.Split(s, ".") {
+		key := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, part = part[:i], part[i:]
+		} else {
+			part = ""
+		}
+
+		if key == "" && part == "" {
+			return nil, fmt.Errorf("empty key in `%s`", s)
+		}
+
+		if key != "" {
+			p = append(p, segment{key: key})
+		}
+
+		for part != "" {
+			end := strings.IndexByte(part, ']')
+			if part[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid index in `%s`", s)
+			}
+
+			index, err := strconv.Atoi(part[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in `%s`", s)
+			}
+
+			p = append(p, segment{index: index, isIndex: true})
+			part = part[end+1:]
+		}
+	}
+
+	return p, nil
+}
+
+// lookup returns value at path in v decoded with json.Decoder.UseNumber.
+func (p path) lookup(v interface{}) (interface{}, bool) {
+	for _, s := range p {
+		if s.isIndex {
+			a, isArray := v.([]interface{})
+			if !isArray || s.index >= len(a) {
+				return nil, false
+			}
+			v = a[s.index]
+			continue
+		}
+
+		o, isObject := v.(map[string]interface{})
+		if !isObject {
+			return nil, false
+		}
+
+		var exists bool
+
+		v, exists = o[s.key]
+		if !exists {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// lookupString returns value at path in v as string. Numbers and booleans
+// are formatted, null and absent values are empty.
+func (p path) lookupString(v interface{}) (string, error) {
+	v, exists := p.lookup(v)
+	if !exists {
+		return "", nil
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", errors.New("value is not a string or number")
+	}
+}
@@ -0,0 +1,65 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPath_lookupString(t *testing.T) {
+	const data = `{
+		"data": {
+			"items": [
+				{"title": "First", "id": 12345678901234, "authors": [{"name": "A"}]},
+				{"title": null}
+			]
+		}
+	}`
+
+	d := json.NewDecoder(strings.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+
+	err := d.Decode(&v)
+	if err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$.data.items[0].title", "First"},
+		{"data.items[0].id", "12345678901234"},
+		{"data.items[0].authors[0].name", "A"},
+		{"data.items[1].title", ""},
+		{"data.items[2].title", ""},
+		{"data.missing", ""},
+	}
+
+	for _, tt := range tests {
+		p, err := parsePath(tt.path)
+		if err != nil {
+			t.Errorf("failed to parse path %s: %v", tt.path, err)
+			continue
+		}
+
+		got, err := p.lookupString(v)
+		if err != nil {
+			t.Errorf("failed to lookup %s: %v", tt.path, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("lookup %s: expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"data.items[x]", "data.items[0", "a..b"} {
+		_, err := parsePath(invalid)
+		if err == nil {
+			t.Errorf("expected error for invalid path %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+// Package jsonapi implements source of ad-hoc JSON endpoints. Requests,
+// pagination and mapping of items to articles are declared in config, so
+// no code is needed to add such an endpoint.
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+	"github.com/dimuls/news-aggregator/sources/link"
+)
+
+type fieldPaths struct {
+	url         path
+	header      path
+	publishedAt path
+	text        path
+	author      path
+}
+
+type Source struct {
+	name     string
+	config   Config
+	location *time.Location
+
+	items  path
+	fields fieldPaths
+	next   path
+
+	fetcher *fetcher.Fetcher
+	log     *logrus.Entry
+}
+
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	err := c.validate()
+	if err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+
+	c = c.withDefaults()
+
+	s := &Source{
+		name:     name,
+		config:   c,
+		location: time.UTC,
+		fetcher:  f,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "jsonapi_source",
+			"source_name": name,
+		}),
+	}
+
+	if c.Timezone != "" {
+		s.location, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, errors.New("failed to load timezone: " + err.Error())
+		}
+	}
+
+	paths := []struct {
+		name string
+		path string
+		dst  *path
+	}{
+		{"items", c.Items, &s.items},
+		{"url", c.Fields.URL, &s.fields.url},
+		{"header", c.Fields.Header, &s.fields.header},
+		{"publishedAt", c.Fields.PublishedAt, &s.fields.publishedAt},
+		{"text", c.Fields.Text, &s.fields.text},
+		{"author", c.Fields.Author, &s.fields.author},
+		{"next", c.Pagination.Next, &s.next},
+	}
+
+	for _, p := range paths {
+		*p.dst, err = parsePath(p.path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s path: %v", p.name, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.location
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas     = make(chan entity.FetchedArticle)
+		errs    = make(chan error, 1)
+		as      []entity.Article
+		nextCur = cur
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil {
+			s.log.WithError(fa.Err).WithField("url", fa.Article.URL).
+				Warning("failed to fetch article, skipping")
+		} else {
+			as = append(as, fa.Article)
+		}
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	return as, nextCur, nil
+}
+
+// StreamArticles sends not seen articles published not before from to fas.
+// Items which failed to map and the articles skipped because of reached
+// max pages are sent as failures, they aren't fetched again.
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	as, failures, err := s.articlesFrom(pos.From(from), pos)
+	if err != nil {
+		return err
+	}
+
+	p := cursor.NewProgress(pos)
+
+	for _, f := range failures {
+		fas <- entity.FetchedArticle{
+			Article: entity.Article{
+				URL:        f.URL,
+				SourceName: s.name,
+			},
+			Cursor: p.Position().Encode(),
+			Err:    errors.New(f.Reason),
+		}
+	}
+
+	for _, a := range as {
+		p.Fetched(a)
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+		}
+	}
+
+	return nil
+}
+
+func (s *Source) formURL(from time.Time, page int) string {
+	pc := s.config.Pagination
+
+	offset := page * pc.Limit
+	page += pc.FirstPage
+
+	r := strings.NewReplacer(
+		"{page}", strconv.Itoa(page),
+		"{offset}", strconv.Itoa(offset),
+		"{limit}", strconv.Itoa(pc.Limit),
+		"{from}", url.QueryEscape(s.formatTime(from)),
+	)
+
+	return r.Replace(s.config.URL)
+}
+
+// articlesFrom returns not seen articles published not before from and
+// failures of the items which couldn't be mapped. When paging of the newest
+// first listing stops at MaxPages, the articles between from and the
+// returned ones aren't fetched, it's returned as failure of the next page.
+func (s *Source) articlesFrom(from time.Time, pos cursor.Position) (
+	as []entity.Article, failures []entity.FetchFailure, err error) {
+
+	var (
+		pURL = s.formURL(from, 0)
+
+		// Publish times of the first and the last listed items.
+		first, last time.Time
+	)
+
+	for page := 0; pURL != ""; page++ {
+		if page == s.config.Pagination.MaxPages {
+			if last.Before(first) {
+				failures = append(failures, entity.FetchFailure{
+					URL: pURL,
+					Reason: fmt.Sprintf("max pages %d reached, articles "+
+						"published from %s to %s weren't fetched",
+						page, from.Format(time.RFC3339),
+						last.Format(time.RFC3339)),
+				})
+			}
+			break
+		}
+
+		v, err := s.get(pURL)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		items, err := s.pageItems(v)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var hasNew bool
+
+		for i, item := range items {
+			a, err := s.article(item, pURL)
+			if err != nil {
+				failures = append(failures, entity.FetchFailure{
+					URL: pURL,
+					Reason: fmt.Sprintf("failed to map item #%d: %v",
+						i, err),
+				})
+				continue
+			}
+
+			if first.IsZero() {
+				first = a.PublishedAt
+			}
+
+			last = a.PublishedAt
+
+			if a.PublishedAt.Before(from) {
+				continue
+			}
+
+			hasNew = true
+
+			if !pos.Seen(a) {
+				as = append(as, a)
+			}
+		}
+
+		if len(items) == 0 || !hasNew {
+			break
+		}
+
+		pURL, err = s.nextPageURL(v, pURL, from, page, len(items))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	return dedup(as), failures, nil
+}
+
+func (s *Source) get(pURL string) (interface{}, error) {
+	log := s.log.WithField("page_url", pURL)
+
+	res, err := s.fetcher.Get(pURL)
+	if err != nil {
+		log.WithError(err).Error("failed to get page URL")
+		return nil, errors.New("failed to HTTP get page URL: " + err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		log.WithField("status_code", res.StatusCode).
+			Error("get page returned not OK status code")
+		return nil, errors.New("not OK status code")
+	}
+
+	d := json.NewDecoder(bytes.NewReader(res.Body))
+	d.UseNumber()
+
+	var v interface{}
+
+	err = d.Decode(&v)
+	if err != nil {
+		return nil, errors.New("failed to decode page: " + err.Error())
+	}
+
+	return v, nil
+}
+
+func (s *Source) pageItems(v interface{}) ([]interface{}, error) {
+	v, exists := s.items.lookup(v)
+	if !exists || v == nil {
+		return nil, nil
+	}
+
+	items, isArray := v.([]interface{})
+	if !isArray {
+		return nil, errors.New("items is not an array")
+	}
+
+	return items, nil
+}
+
+// nextPageURL returns URL of the page after the given one, it's empty on
+// the last page.
+func (s *Source) nextPageURL(v interface{}, pURL string, from time.Time,
+	page, items int) (string, error) {
+
+	switch s.config.Pagination.Type {
+	case PaginationPage, PaginationOffset:
+		if items < s.config.Pagination.Limit {
+			return "", nil
+		}
+		return s.formURL(from, page+1), nil
+	case PaginationNext:
+		return s.nextLink(v, pURL)
+	default:
+		return "", nil
+	}
+}
+
+// nextLink returns next page link resolved against the page URL, it's
+// empty on the last page.
+func (s *Source) nextLink(v interface{}, pURL string) (string, error) {
+	next, err := s.next.lookupString(v)
+	if err != nil {
+		return "", errors.New("failed to get next link: " + err.Error())
+	}
+
+	if next == "" {
+		return "", nil
+	}
+
+	return link.Resolve(pURL, next)
+}
+
+func (s *Source) article(item interface{}, pURL string) (
+	entity.Article, error) {
+
+	var (
+		a   = entity.Article{SourceName: s.name}
+		ps  = s.fields
+		err error
+
+		publishedAt, text string
+	)
+
+	fields := []struct {
+		name string
+		path path
+		dst  *string
+	}{
+		{"url", ps.url, &a.URL},
+		{"header", ps.header, &a.Header},
+		{"publishedAt", ps.publishedAt, &publishedAt},
+		{"text", ps.text, &text},
+		{"author", ps.author, &a.Author},
+	}
+
+	for _, f := range fields {
+		if f.path == nil {
+			continue
+		}
+		*f.dst, err = f.path.lookupString(item)
+		if err != nil {
+			return entity.Article{}, fmt.Errorf("failed to get %s: %v",
+				f.name, err)
+		}
+		*f.dst = strings.TrimSpace(*f.dst)
+	}
+
+	if a.URL == "" {
+		return entity.Article{}, errors.New("empty url")
+	}
+
+	a.URL, err = link.Resolve(pURL, a.URL)
+	if err != nil {
+		return entity.Article{}, err
+	}
+
+	a.PublishedAt, err = s.parseTime(publishedAt)
+	if err != nil {
+		return entity.Article{}, errors.New("failed to parse publishedAt: " +
+			err.Error())
+	}
+
+	if s.config.TextHTML {
+		text = extract.HTMLText(text)
+	}
+
+	a.Text = text
+
+	return a, nil
+}
+
+func (s *Source) parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, errors.New("empty value")
+	}
+
+	switch s.config.DateLayout {
+	case "unix", "unixms":
+		ts, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if s.config.DateLayout == "unixms" {
+			ts /= 1000
+		}
+		sec := int64(ts)
+		return time.Unix(sec, int64((ts-float64(sec))*1e9)).In(s.location),
+			nil
+	case "":
+		return time.Parse(time.RFC3339, v)
+	default:
+		return time.ParseInLocation(s.config.DateLayout, v, s.location)
+	}
+}
+
+func (s *Source) formatTime(t time.Time) string {
+	switch s.config.DateLayout {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "":
+		return t.In(s.location).Format(time.RFC3339)
+	default:
+		return t.In(s.location).Format(s.config.DateLayout)
+	}
+}
+
+// dedup removes repeated URLs of sorted articles, they appear when items
+// shift between pages during paging.
+func dedup(as []entity.Article) []entity.Article {
+	var (
+		res  []entity.Article
+		seen = map[string]struct{}{}
+	)
+
+	for _, a := range as {
+		if _, exists := seen[a.URL]; exists {
+			continue
+		}
+		seen[a.URL] = struct{}{}
+		res = append(res, a)
+	}
+
+	return res
+}
@@ -0,0 +1,319 @@
+package jsonapi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+)
+
+var from = time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC)
+
+func newTestSource(t *testing.T, c Config) *Source {
+	tr, err := replay.NewTransport(filepath.Join("testdata", "api.json"),
+		replay.Replay)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: true,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("example", c, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	return s
+}
+
+func streamArticles(s *Source, from time.Time, cur string) (
+	[]entity.FetchedArticle, error) {
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+	}
+
+	return res, <-errs
+}
+
+func urls(as []entity.Article) []string {
+	var us []string
+	for _, a := range as {
+		us = append(us, a.URL)
+	}
+	return us
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSource_Articles_Page(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL:   "https://api.example.com/v1/news?page={page}&size={limit}&since={from}",
+		Items: "$.data.items",
+		Fields: FieldsConfig{
+			URL:         "links.web",
+			Header:      "title",
+			PublishedAt: "published_at",
+			Text:        "body_html",
+			Author:      "authors[0].name",
+		},
+		TextHTML:   true,
+		DateLayout: "2006-01-02 15:04:05",
+		Timezone:   "Europe/Moscow",
+		Pagination: PaginationConfig{
+			Type:      PaginationPage,
+			Limit:     2,
+			FirstPage: 1,
+		},
+	})
+
+	as, _, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	expectedURLs := []string{
+		"https://api.example.com/news/1",
+		"https://example.com/news/2",
+		"https://api.example.com/news/3",
+	}
+
+	if !equalStrings(urls(as), expectedURLs) {
+		t.Fatalf("expected articles %q, got %q", expectedURLs, urls(as))
+	}
+
+	a := as[0]
+
+	if !a.PublishedAt.Equal(time.Date(2019, 11, 20, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected publish time %v", a.PublishedAt)
+	}
+
+	if a.Header != "Первая новость" ||
+		a.Text != "Текст первой новости.\nВторой абзац." ||
+		a.Author != "Иван Иванов" ||
+		a.SourceName != "example" {
+		t.Errorf("unexpected article %+v", a)
+	}
+
+	if as[1].Text != "Текст второй новости." || as[1].Author != "" {
+		t.Errorf("unexpected article %+v", as[1])
+	}
+
+	fas, err := streamArticles(s, from, "")
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	if len(fas) != 4 || fas[0].Err == nil {
+		t.Fatalf("expected failure of item without link first, got %+v",
+			fas)
+	}
+}
+
+func TestSource_Articles_Offset(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL: "https://api.example.com/offset?offset={offset}&limit={limit}",
+		Fields: FieldsConfig{
+			URL:         "url",
+			PublishedAt: "ts",
+		},
+		DateLayout: "unix",
+		Pagination: PaginationConfig{
+			Type:  PaginationOffset,
+			Limit: 2,
+		},
+	})
+
+	as, cur, err := s.Articles(from.Add(3*time.Hour), "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	expectedURLs := []string{
+		"https://api.example.com/a/2",
+		"https://api.example.com/a/3",
+		"https://api.example.com/a/4",
+	}
+
+	if !equalStrings(urls(as), expectedURLs) {
+		t.Fatalf("expected articles %q, got %q", expectedURLs, urls(as))
+	}
+
+	if !as[0].PublishedAt.Equal(time.Date(2019, 11, 20, 13, 0, 0, 0,
+		time.UTC)) {
+		t.Errorf("unexpected publish time %v", as[0].PublishedAt)
+	}
+
+	as, _, err = s.Articles(from, cur)
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 0 {
+		t.Errorf("expected no articles after cursor, got %q", urls(as))
+	}
+}
+
+func TestSource_Articles_Next(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL:   "https://api.example.com/next",
+		Items: "items",
+		Fields: FieldsConfig{
+			URL:         "url",
+			PublishedAt: "ms",
+		},
+		DateLayout: "unixms",
+		Pagination: PaginationConfig{
+			Type: PaginationNext,
+			Next: "$.next",
+		},
+	})
+
+	as, _, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	expectedURLs := []string{
+		"https://api.example.com/n/1",
+		"https://api.example.com/n/2",
+	}
+
+	if !equalStrings(urls(as), expectedURLs) {
+		t.Fatalf("expected articles %q, got %q", expectedURLs, urls(as))
+	}
+
+	if !as[0].PublishedAt.Equal(time.Date(2019, 11, 20, 12, 0, 0,
+		500*int(time.Millisecond), time.UTC)) {
+		t.Errorf("unexpected publish time %v", as[0].PublishedAt)
+	}
+}
+
+func TestSource_Articles_MaxPages(t *testing.T) {
+	s := newTestSource(t, Config{
+		URL: "https://api.example.com/gap?page={page}",
+		Fields: FieldsConfig{
+			URL:         "url",
+			PublishedAt: "date",
+		},
+		Pagination: PaginationConfig{
+			Type:     PaginationPage,
+			Limit:    1,
+			MaxPages: 2,
+		},
+	})
+
+	fas, err := streamArticles(s, from, "")
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	if len(fas) != 3 {
+		t.Fatalf("expected gap failure and 2 articles, got %+v", fas)
+	}
+
+	if fas[0].Err == nil || fas[0].Article.URL !=
+		"https://api.example.com/gap?page=2" {
+		t.Errorf("expected gap failure, got %+v", fas[0])
+	}
+
+	// Cursor is advanced over the fetched articles, so the next fetch
+	// doesn't stall on the same pages.
+	as, _, err := s.Articles(from, fas[2].Cursor)
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 0 {
+		t.Errorf("expected no articles after cursor, got %q", urls(as))
+	}
+}
+
+func TestSource_parseTime(t *testing.T) {
+	tests := []struct {
+		layout    string
+		value     string
+		expected  time.Time
+		formatted string
+	}{
+		{"", "2019-11-20T12:00:00+03:00",
+			time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC),
+			"2019-11-20T12:00:00+03:00"},
+		{"unix", "1574240400",
+			time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC),
+			"1574240400"},
+		{"unix", "1574240400.25",
+			time.Date(2019, 11, 20, 9, 0, 0, 250000000, time.UTC),
+			"1574240400"},
+		{"unixms", "1574240400250",
+			time.Date(2019, 11, 20, 9, 0, 0, 250000000, time.UTC),
+			"1574240400250"},
+		{"02.01.2006 15:04", "20.11.2019 12:00",
+			time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC),
+			"20.11.2019 12:00"},
+	}
+
+	for _, test := range tests {
+		s := &Source{
+			config:   Config{DateLayout: test.layout},
+			location: time.FixedZone("MSK", 3*60*60),
+		}
+
+		actual, err := s.parseTime(test.value)
+		if err != nil {
+			t.Errorf("%s %s: failed to parse: %v", test.layout, test.value,
+				err)
+			continue
+		}
+
+		if !actual.Equal(test.expected) {
+			t.Errorf("%s %s: expected %v, got %v", test.layout, test.value,
+				test.expected, actual)
+		}
+
+		formatted := s.formatTime(actual)
+		if formatted != test.formatted {
+			t.Errorf("%s %s: expected formatted %s, got %s", test.layout,
+				test.value, test.formatted, formatted)
+		}
+	}
+
+	for _, layout := range []string{"", "unix", "unixms"} {
+		s := &Source{config: Config{DateLayout: layout}, location: time.UTC}
+
+		_, err := s.parseTime("not a time")
+		if err == nil {
+			t.Errorf("%s: expected error", layout)
+		}
+	}
+}
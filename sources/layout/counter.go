@@ -0,0 +1,60 @@
+// Package layout counts matches of the HTML selectors used by scraping
+// sources, so the aggregator can notice when a site layout changes and
+// selectors stop matching.
+package layout
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// Counter counts selector matches, zero value is ready to use. It's safe
+// for concurrent use.
+type Counter struct {
+	mx     sync.Mutex
+	counts map[string]entity.SelectorCount
+}
+
+// Find finds selector in sel like sel.Find does and counts the matches.
+func (c *Counter) Find(sel *goquery.Selection, selector string) *goquery.Selection {
+	found := sel.Find(selector)
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.counts == nil {
+		c.counts = map[string]entity.SelectorCount{}
+	}
+
+	sc := c.counts[selector]
+	sc.Selector = selector
+	sc.Lookups++
+	sc.Matches += found.Length()
+	c.counts[selector] = sc
+
+	return found
+}
+
+// Take returns counts sorted by selector and resets them.
+func (c *Counter) Take() []entity.SelectorCount {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var scs []entity.SelectorCount
+
+	for _, sc := range c.counts {
+		scs = append(scs, sc)
+	}
+
+	sort.Slice(scs, func(i, j int) bool {
+		return scs[i].Selector < scs[j].Selector
+	})
+
+	c.counts = nil
+
+	return scs
+}
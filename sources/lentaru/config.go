@@ -0,0 +1,26 @@
+package lentaru
+
+import "errors"
+
+// Config controls how article pages are fetched: Workers fetch article
+// texts in parallel while rate and per host concurrency are limited by
+// the shared fetcher.
+type Config struct {
+	Workers int `json:"workers"`
+}
+
+const defaultWorkers = 4
+
+func (c Config) withDefaults() Config {
+	if c.Workers == 0 {
+		c.Workers = defaultWorkers
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	if c.Workers < 0 {
+		return errors.New("negative workers")
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package lentaru
+
+import "github.com/dimuls/news-aggregator/sources/scraper"
+
+// ScraperConfig expresses lenta.ru day listings as a generic scraper
+// source config. Generic scraper has no article types, so its body
+// selector covers layouts of all types.
+var ScraperConfig = scraper.Config{
+	ListURL:            baseURL + "/{year}/{month}/{day}/",
+	ItemSelector:       ".b-tabloid > .item",
+	LinkSelector:       ".titles > h3 > a",
+	TimeSelector:       ".time",
+	HeaderSelector:     ".titles > h3 > a > span",
+	BodySelector:       anyBodySelector,
+	PageHeaderSelector: ".b-topic__title",
+	TimeFormat:         "15:04",
+	Timezone:           "Europe/Moscow",
+	RedirectMeansEmpty: true,
+}
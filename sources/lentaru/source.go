@@ -1,6 +1,7 @@
 package lentaru
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html"
@@ -14,73 +15,203 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+	"github.com/dimuls/news-aggregator/sources/layout"
 )
 
 const SourceName = "lenta.ru"
 
 type Source struct {
-	moscow *time.Location
-	http   *http.Client
-	log    *logrus.Entry
+	name      string
+	workers   int
+	moscow    *time.Location
+	fetcher   *fetcher.Fetcher
+	selectors layout.Counter
+	now       func() time.Time
+	log       *logrus.Entry
 }
 
-func NewSource() (*Source, error) {
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		name = SourceName
+	}
+
+	err := c.validate()
+	if err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+
+	c = c.withDefaults()
+
 	moscow, err := time.LoadLocation("Europe/Moscow")
 	if err != nil {
 		return nil, errors.New("failed to load moscow location: " + err.Error())
 	}
 
 	return &Source{
-		moscow: moscow,
-		http: &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		},
-		log: logrus.WithField("subsystem", "lentaru_source"),
+		name:    name,
+		workers: c.Workers,
+		moscow:  moscow,
+		fetcher: f,
+		now:     time.Now,
+		log:     logrus.WithField("subsystem", "lentaru_source"),
 	}, nil
 }
 
 func (s *Source) Name() string {
-	return SourceName
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.moscow
 }
 
+// SelectorCounts returns counts of selector matches since the previous
+// call.
+func (s *Source) SelectorCounts() []entity.SelectorCount {
+	return s.selectors.Take()
+}
+
+// toDateWithTime returns time of dt date in Moscow with the given hour
+// and minute.
 func (s *Source) toDateWithTime(dt time.Time, hour, minute int) time.Time {
+	dt = dt.In(s.moscow)
 	return time.Date(dt.Year(), dt.Month(), dt.Day(),
 		hour, minute, 0, 0, s.moscow)
 }
 
-func (s *Source) Articles(from time.Time) ([]entity.Article, error) {
-	now := time.Now()
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas      = make(chan entity.FetchedArticle)
+		errs     = make(chan error, 1)
+		as       []entity.Article
+		nextCur  = cur
+		fetchErr error
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil && fetchErr == nil {
+			fetchErr = fa.Err
+		}
+		as = append(as, fa.Article)
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fetchErr != nil {
+		return nil, "", fetchErr
+	}
+
+	return as, nextCur, nil
+}
+
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	from = pos.From(from)
+
+	now := s.now()
 
 	if from.After(now) {
-		return nil, errors.New("from is after now")
+		return errors.New("from is after now")
 	}
 
 	fromDay := s.toDateWithTime(from, 0, 0)
 	nowDay := s.toDateWithTime(now, 0, 0)
 
-	as, err := s.articles(from)
-	if err != nil {
-		return nil, errors.New("failed to get articles: " + err.Error())
-	}
+	dayFrom := from
+
+	p := cursor.NewProgress(pos)
 
 	for {
+		err = s.streamDay(dayFrom, p, fas)
+		if err != nil {
+			return err
+		}
+
 		if fromDay.Equal(nowDay) {
 			break
 		}
 
-		fromDay = fromDay.Add(24 * time.Hour)
+		fromDay = fromDay.AddDate(0, 0, 1)
+		dayFrom = fromDay
+	}
+
+	return nil
+}
+
+// DayArticles sends all articles of the day to fas. Only year, month and
+// day of the given day are used.
+func (s *Source) DayArticles(day time.Time,
+	fas chan<- entity.FetchedArticle) error {
+
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.moscow)
+
+	return s.streamDay(day, cursor.NewProgress(cursor.Position{}), fas)
+}
+
+// streamDay sends articles of the from day published not before from and
+// not seen according to p. Position of p is advanced over the successfully
+// fetched articles only, so the failed ones are fetched again next time.
+func (s *Source) streamDay(from time.Time, p *cursor.Progress,
+	fas chan<- entity.FetchedArticle) error {
+
+	as, failures, err := s.articles(from, p.Position())
+	if err != nil {
+		return errors.New("failed to get articles: " + err.Error())
+	}
+
+	for _, f := range failures {
+		fas <- entity.FetchedArticle{
+			Article: entity.Article{
+				URL:        f.URL,
+				SourceName: s.name,
+			},
+			Cursor: p.Position().Encode(),
+			Err:    errors.New(f.Reason),
+		}
+	}
+
+	pages := s.articlePages(as)
 
-		currAs, err := s.articles(fromDay)
+	for i, a := range as {
+		pr := <-pages[i]
+
+		err = pr.err
 		if err != nil {
-			return nil, errors.New("failed to get articles: " + err.Error())
+			err = errors.New("failed to get article page: " + err.Error())
+			p.Failed(a)
+		} else {
+			a = pr.article
+			p.Fetched(a)
 		}
 
-		as = append(as, currAs...)
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+			Err:     err,
+		}
 	}
 
-	return as, nil
+	return nil
 }
 
 const baseURL = "https://lenta.ru"
@@ -124,71 +255,79 @@ func (s *Source) setDateTime(dt time.Time, timeStr string) (time.Time, error) {
 	return s.toDateWithTime(dt, int(hour), int(minute)), nil
 }
 
-func (s *Source) articles(from time.Time) ([]entity.Article, error) {
+func (s *Source) articles(from time.Time, pos cursor.Position) (
+	[]entity.Article, []entity.FetchFailure, error) {
+
 	asURL := formArticlesURL(from)
 
 	log := s.log.WithField("articles_url", asURL)
 
-	res, err := s.http.Get(asURL)
+	res, err := s.fetcher.GetNoRedirect(asURL)
 	if err != nil {
 		log.WithError(err).Error("failed to get articles URL")
-		return nil, errors.New("failed to HTTP get articles URL: " + err.Error())
+		return nil, nil, errors.New("failed to HTTP get articles URL: " +
+			err.Error())
 	}
 
-	defer res.Body.Close()
-
 	if res.StatusCode != http.StatusOK {
 		if res.StatusCode == http.StatusFound {
-			return nil, nil
+			return nil, nil, nil
 		}
 		log.WithField("status_code", res.StatusCode).
 			Error("get articles returned not OK status code")
-		return nil, errors.New("not OK status code")
+		return nil, nil, errors.New("not OK status code")
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	body, err := res.DecodedBody()
 	if err != nil {
-		return nil, errors.New("failed to parse articles HTML: " + err.Error())
+		return nil, nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, errors.New("failed to parse articles HTML: " +
+			err.Error())
 	}
 
 	var (
-		as      []entity.Article
-		findErr error
+		as       []entity.Article
+		failures []entity.FetchFailure
 	)
 
-	doc.Find(".item.news").EachWithBreak(
-		func(_ int, sel *goquery.Selection) bool {
-			urlPath, urlPathExists := sel.Find(".titles > h3 > a").
-				Attr("href")
-			if !urlPathExists {
-				findErr = errors.New("failed to find article URL")
-				return false
-			}
-
-			timeStr := strings.TrimSpace(sel.Find(".time").Text())
+	items := s.selectors.Find(doc.Selection, ".b-tabloid > .item")
 
-			publishedAt, err := s.setDateTime(from, timeStr)
-			if err != nil {
-				findErr = errors.New("failed to set date time: " + err.Error())
-				return false
-			}
+	items.Each(func(i int, sel *goquery.Selection) {
+		urlPath, urlPathExists := s.selectors.Find(sel, ".titles > h3 > a").
+			Attr("href")
+		if !urlPathExists {
+			failures = append(failures, entity.FetchFailure{
+				URL:    asURL,
+				Reason: fmt.Sprintf("failed to find URL of article #%d", i),
+			})
+			return
+		}
 
-			headerEnc := strings.TrimSpace(
-				sel.Find(".titles > h3 > a > span").Text())
+		timeStr := strings.TrimSpace(s.selectors.Find(sel, ".time").Text())
 
-			as = append(as, entity.Article{
-				URL:         baseURL + urlPath,
-				Header:      html.UnescapeString(headerEnc),
-				PublishedAt: publishedAt,
-				SourceName:  SourceName,
+		publishedAt, err := s.setDateTime(from, timeStr)
+		if err != nil {
+			failures = append(failures, entity.FetchFailure{
+				URL:    baseURL + urlPath,
+				Reason: "failed to set date time: " + err.Error(),
 			})
+			return
+		}
+
+		header := s.selectors.Find(sel, ".titles > h3 > a > span").Text()
 
-			return true
+		as = append(as, entity.Article{
+			URL:         baseURL + urlPath,
+			Type:        articleType(baseURL + urlPath),
+			Header:      extract.Header(header),
+			PublishedAt: publishedAt,
+			SourceName:  s.name,
 		})
-	if findErr != nil {
-		return nil, errors.New("failed to find all articles: " +
-			findErr.Error())
-	}
+	})
 
 	sort.Sort(entity.ArticlesByPublishedAt(as))
 
@@ -202,44 +341,194 @@ func (s *Source) articles(from time.Time) ([]entity.Article, error) {
 	}
 
 	if fromIndex == -1 {
-		return nil, nil
+		return nil, failures, nil
 	}
 
-	as = as[fromIndex:]
+	return pos.Filter(as[fromIndex:]), failures, nil
+}
 
-	for i := range as {
-		as[i].Text, err = s.articleText(as[i].URL)
-		if err != nil {
-			return nil, errors.New("failed to get article text: " + err.Error())
+type pageResult struct {
+	article entity.Article
+	err     error
+}
+
+// articlePages fetches pages of articles using workers pool. Result of
+// i-th article is sent to i-th channel, so results can be consumed in
+// the articles order as soon as they are ready.
+func (s *Source) articlePages(as []entity.Article) []chan pageResult {
+	results := make([]chan pageResult, len(as))
+
+	for i := range results {
+		results[i] = make(chan pageResult, 1)
+	}
+
+	jobs := make(chan int)
+
+	go func() {
+		for i := range as {
+			jobs <- i
 		}
+		close(jobs)
+	}()
+
+	workers := s.workers
+	if workers > len(as) {
+		workers = len(as)
 	}
 
-	return as, nil
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				a, err := s.pageArticle(as[i])
+				results[i] <- pageResult{article: a, err: err}
+			}
+		}()
+	}
+
+	return results
 }
 
-func (s *Source) articleText(aURL string) (string, error) {
-	res, err := s.http.Get(aURL)
+func (s *Source) articleDoc(aURL string) (*goquery.Document, error) {
+	res, err := s.fetcher.Get(aURL)
 	if err != nil {
-		return "", errors.New("failed to HTTP get article URL: " + err.Error())
+		return nil, errors.New("failed to HTTP get article URL: " + err.Error())
 	}
 
-	defer res.Body.Close()
-
 	if res.StatusCode != http.StatusOK {
-		return "", errors.New("not OK status code")
+		return nil, errors.New("not OK status code")
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	body, err := res.DecodedBody()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("failed to parse article HTML: " + err.Error())
+	}
+
+	return doc, nil
+}
+
+// pageArticle fetches article page and returns a with the page text and
+// details.
+func (s *Source) pageArticle(a entity.Article) (entity.Article, error) {
+	doc, err := s.articleDoc(a.URL)
 	if err != nil {
-		return "", errors.New("failed to parse article HTML: " + err.Error())
+		return entity.Article{}, err
 	}
 
+	s.setDetails(&a, doc)
+
+	a.Text, a.ExtractionMethod = s.docText(doc, a.Type, &s.selectors)
+
+	return a, nil
+}
+
+// setDetails sets rubric, tags, author, lead, image URL and modification
+// time of a found in doc. Details missing in doc are kept untouched.
+// Details are optional, so their selectors aren't counted.
+func (s *Source) setDetails(a *entity.Article, doc *goquery.Document) {
+	rubric := strings.TrimSpace(doc.Find(
+		`meta[property="article:section"]`).AttrOr("content", ""))
+	if rubric != "" {
+		a.Categories = []string{html.UnescapeString(rubric)}
+	}
+
+	var tags []string
+
+	doc.Find(`meta[property="article:tag"]`).
+		Each(func(_ int, sel *goquery.Selection) {
+			tag := strings.TrimSpace(sel.AttrOr("content", ""))
+			if tag != "" {
+				tags = append(tags, html.UnescapeString(tag))
+			}
+		})
+
+	if len(tags) > 0 {
+		a.Tags = tags
+	}
+
+	var authors []string
+
+	doc.Find(".b-label__credits .name").
+		Each(func(_ int, sel *goquery.Selection) {
+			author := strings.TrimSpace(sel.Text())
+			if author != "" {
+				authors = append(authors, author)
+			}
+		})
+
+	if len(authors) > 0 {
+		a.Author = strings.Join(authors, ", ")
+	}
+
+	lead := strings.TrimSpace(doc.Find(".b-topic__rightcol").Text())
+	if lead != "" {
+		a.Lead = lead
+	}
+
+	image := strings.TrimSpace(doc.Find(
+		`meta[property="og:image"]`).AttrOr("content", ""))
+	if image != "" {
+		a.ImageURL = image
+	}
+
+	modified := strings.TrimSpace(doc.Find(
+		`meta[property="article:modified_time"]`).AttrOr("content", ""))
+	if modified != "" {
+		modifiedAt, err := time.Parse(time.RFC3339, modified)
+		if err != nil {
+			s.log.WithError(err).WithField("article_url", a.URL).
+				Warning("failed to parse article modification time")
+		} else {
+			a.ModifiedAt = &modifiedAt
+		}
+	}
+}
+
+// docText returns text of article of the given type and its extraction
+// method, body selector matches are counted by sels. Unmatched body
+// selector falls back to the main content extraction which modifies doc.
+func (s *Source) docText(doc *goquery.Document, aType string,
+	sels *layout.Counter) (string, string) {
+
 	var ps []string
 
-	doc.Find(".b-text > p").Each(func(i int, s *goquery.Selection) {
+	body := sels.Find(doc.Selection, bodySelector(aType))
+
+	body.Each(func(i int, s *goquery.Selection) {
 		ps = append(ps,
 			strings.TrimSpace(html.UnescapeString(s.Text())))
 	})
 
-	return strings.Join(ps, "\n"), nil
+	return extract.WithFallback(doc, strings.Join(ps, "\n"))
+}
+
+// RefetchArticle fetches article page again and returns a with actual
+// header, text and details. Selector matches of refetches aren't counted,
+// so layout drift is detected by the regular fetches only.
+func (s *Source) RefetchArticle(a entity.Article) (entity.Article, error) {
+	doc, err := s.articleDoc(a.URL)
+	if err != nil {
+		return entity.Article{}, err
+	}
+
+	header := extract.Header(doc.Find(".b-topic__title").Text())
+	if header != "" {
+		a.Header = header
+	}
+
+	if a.Type == "" {
+		a.Type = articleType(a.URL)
+	}
+
+	s.setDetails(&a, doc)
+
+	var sels layout.Counter
+
+	a.Text, a.ExtractionMethod = s.docText(doc, a.Type, &sels)
+
+	return a, nil
 }
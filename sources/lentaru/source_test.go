@@ -0,0 +1,250 @@
+package lentaru
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+)
+
+// Tests replay HTTP fixtures from testdata and compare results with the
+// *.want.json files.
+//
+// Both fixtures and wanted results are hand-written after the lenta.ru
+// markup, so tests check the parser against that markup only, not against
+// the live site. Detail selectors (rubric, tags, author, lead, image and
+// modification time) and per-type body layouts aren't verified on real
+// pages yet. Run tests with -record to record fixtures from lenta.ru,
+// then review failures and fix the parser or the wanted results by hand.
+var record = flag.Bool("record", false, "record HTTP fixtures from lenta.ru")
+
+func newTestSource(t *testing.T, cassette string) (*Source, func()) {
+	mode := replay.Replay
+	if *record {
+		mode = replay.Record
+	}
+
+	tr, err := replay.NewTransport(
+		filepath.Join("testdata", cassette+".json"), mode)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: !*record,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("", Config{}, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	s.now = func() time.Time {
+		return time.Date(2019, 11, 20, 12, 0, 0, 0, s.moscow)
+	}
+
+	return s, func() {
+		err := tr.Save()
+		if err != nil {
+			t.Fatalf("failed to save cassette: %v", err)
+		}
+	}
+}
+
+func checkWant(t *testing.T, name string, got interface{}) {
+	path := filepath.Join("testdata", name+".want.json")
+
+	gotData, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+
+	wantData, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read wanted result: %v", err)
+	}
+
+	if !bytes.Equal(gotData, wantData) {
+		t.Errorf("result differs from %s\ngot:\n%s\nwant:\n%s",
+			path, gotData, wantData)
+	}
+}
+
+func TestSource_Articles(t *testing.T) {
+	s, save := newTestSource(t, "articles_from")
+	defer save()
+
+	from := time.Date(2019, 11, 19, 23, 0, 0, 0, s.moscow)
+
+	as, cur, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	checkWant(t, "articles_from", struct {
+		Articles []entity.Article
+		Cursor   string
+	}{as, cur})
+
+	as, nextCur, err := s.Articles(from, cur)
+	if err != nil {
+		t.Fatalf("failed to get articles from cursor: %v", err)
+	}
+
+	if len(as) != 0 {
+		t.Errorf("expected no articles after cursor, got %d", len(as))
+	}
+
+	if nextCur != cur {
+		t.Errorf("expected cursor %s to be kept, got %s", cur, nextCur)
+	}
+
+}
+
+func TestSource_ArticlesUTC(t *testing.T) {
+	s, save := newTestSource(t, "articles_from")
+	defer save()
+
+	// It's already November 20 in Moscow, but still November 19 in UTC.
+	s.now = func() time.Time {
+		return time.Date(2019, 11, 19, 21, 30, 0, 0, time.UTC)
+	}
+
+	from := time.Date(2019, 11, 19, 20, 0, 0, 0, time.UTC)
+
+	as, _, err := s.Articles(from, "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	const want = "https://lenta.ru/news/2019/11/20/weather/"
+
+	for _, a := range as {
+		if a.URL == want {
+			return
+		}
+	}
+
+	t.Errorf("expected %s published at 00:15 in Moscow to be fetched", want)
+}
+
+func TestSource_articles(t *testing.T) {
+	s, save := newTestSource(t, "day_articles")
+	defer save()
+
+	day := time.Date(2019, 11, 18, 0, 0, 0, 0, s.moscow)
+
+	as, failures, err := s.articles(day, cursor.Position{})
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	checkWant(t, "day_articles", struct {
+		Articles []entity.Article
+		Failures []entity.FetchFailure
+	}{as, failures})
+
+	as, failures, err = s.articles(day.AddDate(0, 0, -1), cursor.Position{})
+	if err != nil {
+		t.Fatalf("failed to get articles of redirected day: %v", err)
+	}
+
+	if len(as) != 0 || len(failures) != 0 {
+		t.Errorf("expected redirected day to be empty, got %d articles "+
+			"and %d failures", len(as), len(failures))
+	}
+}
+
+func TestSource_pageArticle(t *testing.T) {
+	s, save := newTestSource(t, "article_page")
+	defer save()
+
+	a, err := s.pageArticle(entity.Article{
+		URL:         "https://lenta.ru/news/2019/11/20/weather/",
+		Header:      "В Москве ожидается «аномальное» тепло",
+		PublishedAt: time.Date(2019, 11, 20, 0, 15, 0, 0, s.moscow),
+		SourceName:  SourceName,
+	})
+	if err != nil {
+		t.Fatalf("failed to get article page: %v", err)
+	}
+
+	if a.ExtractionMethod != extract.MethodSelector {
+		t.Errorf("expected %s extraction method, got %s",
+			extract.MethodSelector, a.ExtractionMethod)
+	}
+
+	checkWant(t, "article_page", a)
+
+	_, err = s.pageArticle(entity.Article{
+		URL: "https://lenta.ru/news/2019/11/20/missing/",
+	})
+	if err == nil {
+		t.Error("expected error for missing article")
+	}
+}
+
+func TestSource_pageArticleTypes(t *testing.T) {
+	s, save := newTestSource(t, "article_page")
+	defer save()
+
+	tests := []struct {
+		url  string
+		typ  string
+		text string
+	}{
+		{
+			url: "https://lenta.ru/articles/2019/11/18/reform/",
+			typ: TypeArticle,
+			text: "Минтруд представил проект изменений.\n" +
+				"Что предлагается\n" +
+				"Проект предусматривает добровольные накопления.\n" +
+				"Реформа не затронет нынешних пенсионеров.",
+		},
+		{
+			url: "https://lenta.ru/photo/2019/11/18/autumn/",
+			typ: TypePhoto,
+			text: "Парк усадьбы Архангельское.\n" +
+				"Берег Москвы-реки в Звенигороде.",
+		},
+	}
+
+	for _, test := range tests {
+		a, err := s.pageArticle(entity.Article{
+			URL:  test.url,
+			Type: articleType(test.url),
+		})
+		if err != nil {
+			t.Fatalf("failed to get article page %s: %v", test.url, err)
+		}
+
+		if a.Type != test.typ {
+			t.Errorf("expected %s type of %s, got %s", test.typ, test.url,
+				a.Type)
+		}
+
+		if a.ExtractionMethod != extract.MethodSelector {
+			t.Errorf("expected %s extraction method of %s, got %s",
+				extract.MethodSelector, test.url, a.ExtractionMethod)
+		}
+
+		if a.Text != test.text {
+			t.Errorf("expected text of %s:\n%s\ngot:\n%s", test.url,
+				test.text, a.Text)
+		}
+	}
+}
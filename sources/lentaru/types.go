@@ -0,0 +1,65 @@
+package lentaru
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Types of lenta.ru articles. The day page lists all of them, type is
+// defined by the first segment of article URL path.
+const (
+	TypeNews    = "news"
+	TypeArticle = "article"
+	TypeColumn  = "column"
+	TypePhoto   = "photo"
+	TypeVideo   = "video"
+)
+
+var pathTypes = map[string]string{
+	"news":     TypeNews,
+	"articles": TypeArticle,
+	"columns":  TypeColumn,
+	"photo":    TypePhoto,
+	"video":    TypeVideo,
+}
+
+// articleType returns type of article with the given URL. Unknown types
+// are named by the URL path segment.
+func articleType(aURL string) string {
+	u, err := url.Parse(aURL)
+	if err != nil {
+		return ""
+	}
+
+	segment := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+
+	if t, known := pathTypes[segment]; known {
+		return t
+	}
+
+	return segment
+}
+
+const defaultBodySelector = ".b-text > p"
+
+// bodySelectors are article body selectors by type. Long reads and
+// columns have subheadings and quotes, galleries and videos keep text in
+// captions and descriptions.
+var bodySelectors = map[string]string{
+	TypeNews:    defaultBodySelector,
+	TypeArticle: ".b-text > p, .b-text > h2, .b-text > blockquote",
+	TypeColumn:  ".b-text > p, .b-text > h2, .b-text > blockquote",
+	TypePhoto:   ".b-text > p, .b-gallery__item-description",
+	TypeVideo:   ".b-text > p, .b-video-box__description",
+}
+
+// anyBodySelector matches bodies of all known types.
+const anyBodySelector = ".b-text > p, .b-text > h2, .b-text > blockquote, " +
+	".b-gallery__item-description, .b-video-box__description"
+
+func bodySelector(aType string) string {
+	if sel, exists := bodySelectors[aType]; exists {
+		return sel
+	}
+	return defaultBodySelector
+}
@@ -0,0 +1,22 @@
+// Package link contains helpers for links found in fetched documents.
+package link
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Resolve resolves possibly relative ref against base URL.
+func Resolve(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", errors.New("failed to parse base URL: " + err.Error())
+	}
+
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.New("failed to parse URL: " + err.Error())
+	}
+
+	return b.ResolveReference(r).String(), nil
+}
@@ -0,0 +1,201 @@
+package mailbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/dimuls/news-aggregator/extract"
+)
+
+// maxPartSize limits size of decoded message part.
+const maxPartSize = 16 << 20
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+type message struct {
+	id      string
+	date    time.Time
+	subject string
+	author  string
+	text    string
+}
+
+type header interface {
+	Get(key string) string
+}
+
+func parseMessage(raw []byte) (message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return message{}, errors.New("failed to read message: " + err.Error())
+	}
+
+	date, err := m.Header.Date()
+	if err != nil {
+		return message{}, errors.New("failed to parse date: " + err.Error())
+	}
+
+	subject, err := wordDecoder.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+
+	msg := message{
+		id:      strings.Trim(m.Header.Get("Message-Id"), "<> \t"),
+		date:    date,
+		subject: strings.TrimSpace(subject),
+	}
+
+	if msg.id == "" {
+		h := sha256.Sum256(raw)
+		msg.id = hex.EncodeToString(h[:]) + "@news-aggregator"
+	}
+
+	ap := mail.AddressParser{WordDecoder: wordDecoder}
+
+	from, err := ap.Parse(m.Header.Get("From"))
+	if err == nil {
+		msg.author = from.Name
+		if msg.author == "" {
+			msg.author = from.Address
+		}
+	}
+
+	plain, html, err := partTexts(m.Header, m.Body)
+	if err != nil {
+		return message{}, errors.New("failed to read body: " + err.Error())
+	}
+
+	msg.text = plainText(plain)
+	if msg.text == "" {
+		msg.text = extract.HTMLText(html)
+	}
+
+	return msg, nil
+}
+
+// partTexts returns the first text/plain and text/html contents found in
+// the message part, attachments are skipped.
+func partTexts(h header, body io.Reader) (string, string, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if d, _, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil &&
+		d == "attachment" {
+		return "", "", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var plain, html string
+
+		mr := multipart.NewReader(body, params["boundary"])
+
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", "", err
+			}
+
+			pPlain, pHTML, err := partTexts(p.Header, p)
+			if err != nil {
+				return "", "", err
+			}
+
+			if plain == "" {
+				plain = pPlain
+			}
+			if html == "" {
+				html = pHTML
+			}
+		}
+
+		return plain, html, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+
+	r := decodeTransfer(h.Get("Content-Transfer-Encoding"), body)
+
+	if cs := params["charset"]; cs != "" {
+		r, err = charset.NewReaderLabel(cs, r)
+		if err != nil {
+			return "", "", errors.New("unsupported charset " + cs)
+		}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxPartSize))
+	if err != nil {
+		return "", "", err
+	}
+
+	if mediaType == "text/html" {
+		return "", string(data), nil
+	}
+
+	return string(data), "", nil
+}
+
+// decodeTransfer decodes part body. Quoted-printable parts of multipart
+// messages are decoded by multipart.Reader already and have no encoding
+// header.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	default:
+		return r
+	}
+}
+
+// plainText joins hard wrapped lines of the paragraphs, which are
+// separated by blank lines, and puts paragraphs on separate lines.
+func plainText(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+
+	var (
+		ps []string
+		p  []string
+	)
+
+	flush := func() {
+		if len(p) > 0 {
+			ps = append(ps, strings.Join(p, " "))
+			p = nil
+		}
+	}
+
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			flush()
+			continue
+		}
+		p = append(p, l)
+	}
+
+	flush()
+
+	return strings.Join(ps, "\n")
+}
@@ -0,0 +1,381 @@
+// Package mailbox implements source of email newsletters stored in local
+// mbox file or Maildir directory. Each message is an article with subject
+// as header and text/plain or HTML stripped body as text. Article URL is
+// "mid:" URL of the message Message-ID.
+//
+// Cursor keeps Message-IDs of the fetched messages with their dates, the
+// ones older than from are pruned since such messages are skipped anyway.
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// Mailbox formats.
+const (
+	FormatMbox    = "mbox"
+	FormatMaildir = "maildir"
+)
+
+// Config declares mailbox source. Format is detected by Path when empty:
+// directory is Maildir and file is mbox.
+type Config struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+type Source struct {
+	name   string
+	path   string
+	format string
+	log    *logrus.Entry
+}
+
+func NewSource(name string, c Config) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	if c.Path == "" {
+		return nil, errors.New("empty path")
+	}
+
+	switch c.Format {
+	case "", FormatMbox, FormatMaildir:
+	default:
+		return nil, errors.New("unknown format `" + c.Format + "`")
+	}
+
+	return &Source{
+		name:   name,
+		path:   c.Path,
+		format: c.Format,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "mailbox_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+type position struct {
+	IDs map[string]time.Time `json:"ids"`
+}
+
+func decodePosition(cur string) (position, error) {
+	pos := position{IDs: map[string]time.Time{}}
+
+	if cur == "" {
+		return pos, nil
+	}
+
+	err := json.Unmarshal([]byte(cur), &pos)
+	if err != nil {
+		return position{}, errors.New("failed to decode cursor: " +
+			err.Error())
+	}
+
+	if pos.IDs == nil {
+		pos.IDs = map[string]time.Time{}
+	}
+
+	return pos, nil
+}
+
+func (p position) encode() string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		// Should never happen: position consists of strings and times.
+		panic("failed to encode cursor: " + err.Error())
+	}
+	return string(data)
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas     = make(chan entity.FetchedArticle)
+		errs    = make(chan error, 1)
+		as      []entity.Article
+		nextCur = cur
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil {
+			s.log.WithError(fa.Err).WithField("url", fa.Article.URL).
+				Warning("failed to parse message, skipping")
+		} else if fa.Article.URL != "" {
+			as = append(as, fa.Article)
+		}
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	return as, nextCur, nil
+}
+
+// StreamArticles sends not seen messages dated not before from to fas.
+// Messages which failed to parse are sent as failures with "mid:" URL when
+// Message-ID is found or with the message location otherwise.
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := decodePosition(cur)
+	if err != nil {
+		return err
+	}
+
+	for id, date := range pos.IDs {
+		if date.Before(from) {
+			delete(pos.IDs, id)
+		}
+	}
+
+	var (
+		as       []entity.Article
+		failures []entity.FetchFailure
+		// Message-IDs of articles by URL.
+		ids = map[string]string{}
+	)
+
+	err = s.messages(func(raw []byte, loc string) {
+		m, err := parseMessage(raw)
+		if err != nil {
+			failures = append(failures, entity.FetchFailure{
+				URL:    failureURL(raw, loc),
+				Reason: err.Error(),
+			})
+			return
+		}
+
+		if m.date.Before(from) {
+			return
+		}
+
+		if _, seen := pos.IDs[m.id]; seen {
+			return
+		}
+
+		mURL := messageURL(m.id)
+
+		if _, exists := ids[mURL]; exists {
+			return
+		}
+
+		ids[mURL] = m.id
+
+		as = append(as, entity.Article{
+			URL:         mURL,
+			Header:      m.subject,
+			PublishedAt: m.date,
+			Text:        m.text,
+			Author:      m.author,
+			SourceName:  s.name,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range failures {
+		fas <- entity.FetchedArticle{
+			Article: entity.Article{
+				URL:        f.URL,
+				SourceName: s.name,
+			},
+			Cursor: pos.encode(),
+			Err:    errors.New(f.Reason),
+		}
+	}
+
+	if len(as) == 0 {
+		// Only the pruned cursor is sent.
+		fas <- entity.FetchedArticle{Cursor: pos.encode()}
+		return nil
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	for _, a := range as {
+		pos.IDs[ids[a.URL]] = a.PublishedAt
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  pos.encode(),
+		}
+	}
+
+	return nil
+}
+
+func messageURL(id string) string {
+	return "mid:" + url.PathEscape(id)
+}
+
+// failureURL returns "mid:" URL of the message which failed to parse when
+// its Message-ID is found, or its location otherwise.
+func failureURL(raw []byte, loc string) string {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return loc
+	}
+
+	id := strings.Trim(m.Header.Get("Message-Id"), "<> \t")
+	if id == "" {
+		return loc
+	}
+
+	return messageURL(id)
+}
+
+// messages calls f with raw content and location of each mailbox message.
+// Location is the file path of Maildir message and the mbox path with
+// message number of mbox message.
+func (s *Source) messages(f func(raw []byte, loc string)) error {
+	format := s.format
+
+	if format == "" {
+		fi, err := os.Stat(s.path)
+		if err != nil {
+			return errors.New("failed to stat mailbox: " + err.Error())
+		}
+
+		format = FormatMbox
+		if fi.IsDir() {
+			format = FormatMaildir
+		}
+	}
+
+	if format == FormatMaildir {
+		return maildirMessages(s.path, f)
+	}
+
+	return mboxMessages(s.path, f)
+}
+
+// maildirMessages reads messages from new and cur Maildir subdirectories.
+// Messages are left in place, so the ones read by other clients are still
+// found in cur.
+func maildirMessages(path string, f func(raw []byte, loc string)) error {
+	for _, sub := range []string{"new", "cur"} {
+		dir := filepath.Join(path, sub)
+
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return errors.New("failed to read Maildir: " + err.Error())
+		}
+
+		for _, fi := range fis {
+			if fi.IsDir() {
+				continue
+			}
+
+			mPath := filepath.Join(dir, fi.Name())
+
+			raw, err := ioutil.ReadFile(mPath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					// Moved from new to cur meanwhile, it's read from cur.
+					continue
+				}
+				return errors.New("failed to read message: " + err.Error())
+			}
+
+			f(raw, mPath)
+		}
+	}
+
+	return nil
+}
+
+var mboxFrom = []byte("From ")
+
+// mboxMessages reads messages from mbox file. Messages start with "From "
+// lines following blank lines, the escaped ">From " lines of messages are
+// unescaped.
+func mboxMessages(path string, f func(raw []byte, loc string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.New("failed to open mbox: " + err.Error())
+	}
+
+	defer file.Close()
+
+	var (
+		r         = bufio.NewReader(file)
+		msg       bytes.Buffer
+		in        bool
+		prevBlank = true
+		n         int
+	)
+
+	loc := func() string {
+		return path + "#" + strconv.Itoa(n)
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case prevBlank && bytes.HasPrefix(line, mboxFrom):
+				if in {
+					f(msg.Bytes(), loc())
+				}
+				msg = bytes.Buffer{}
+				in = true
+				n++
+			case !in:
+			case line[0] == '>' &&
+				bytes.HasPrefix(bytes.TrimLeft(line, ">"), mboxFrom):
+				msg.Write(line[1:])
+			default:
+				msg.Write(line)
+			}
+
+			prevBlank = len(bytes.TrimSpace(line)) == 0
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.New("failed to read mbox: " + err.Error())
+		}
+	}
+
+	if in {
+		f(msg.Bytes(), loc())
+	}
+
+	return nil
+}
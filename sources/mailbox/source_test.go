@@ -0,0 +1,172 @@
+package mailbox
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+func newTestSource(t *testing.T, path string) *Source {
+	s, err := NewSource("newsletters", Config{
+		Path: filepath.Join("testdata", path),
+	})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	return s
+}
+
+func checkArticle(t *testing.T, a entity.Article, url, header, author,
+	text string, publishedAt time.Time) {
+
+	t.Helper()
+
+	if a.URL != url || a.Header != header || a.Author != author ||
+		a.Text != text || a.SourceName != "newsletters" {
+		t.Errorf("unexpected article %+v", a)
+	}
+
+	if !a.PublishedAt.Equal(publishedAt) {
+		t.Errorf("%s: expected publish time %v, got %v", a.URL,
+			publishedAt, a.PublishedAt)
+	}
+}
+
+func TestSource_Articles_Mbox(t *testing.T) {
+	s := newTestSource(t, "newsletters.mbox")
+
+	as, _, err := s.Articles(time.Date(2019, 11, 20, 0, 0, 0, 0, time.UTC), "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(as))
+	}
+
+	// Escaped >From line is unescaped, From line inside paragraph doesn't
+	// start a message.
+	checkArticle(t, as[0], "mid:morning-1@example.com", "Утренние новости",
+		"Редакция", "Первая строка абзаца продолжается здесь.\n"+
+			"From the editor: hello. From the middle of the paragraph.",
+		time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC))
+
+	// Quoted-printable koi8-r plain part is preferred to HTML one.
+	checkArticle(t, as[1], "mid:evening-1@example.com", "Evening digest",
+		"news@example.com", "Вечерний выпуск: главное за день.\n"+
+			"Второй абзац.",
+		time.Date(2019, 11, 20, 18, 0, 0, 0, time.UTC))
+}
+
+func TestSource_Articles_Maildir(t *testing.T) {
+	s := newTestSource(t, "Maildir")
+
+	as, _, err := s.Articles(time.Date(2019, 11, 20, 0, 0, 0, 0, time.UTC), "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(as))
+	}
+
+	checkArticle(t, as[0], "mid:read-1@example.com", "Read digest",
+		"Weekly", "Digest read by another client, still an article.",
+		time.Date(2019, 11, 20, 12, 0, 0, 0, time.UTC))
+
+	// Message without Message-ID gets ID from its content hash, base64
+	// windows-1251 HTML part is used and attachment is skipped.
+	a := as[1]
+
+	if !strings.HasPrefix(a.URL, "mid:") ||
+		!strings.HasSuffix(a.URL, "@news-aggregator") {
+		t.Errorf("expected content hash URL, got %s", a.URL)
+	}
+
+	checkArticle(t, a, a.URL, "Weekly sale", "Shop",
+		"Скидки недели.\nТолько до пятницы.",
+		time.Date(2019, 11, 21, 7, 0, 0, 0, time.UTC))
+
+	again, _, err := s.Articles(time.Date(2019, 11, 20, 0, 0, 0, 0,
+		time.UTC), "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(again) != 2 || again[1].URL != a.URL {
+		t.Errorf("expected stable content hash URL")
+	}
+}
+
+func TestSource_StreamArticles(t *testing.T) {
+	s := newTestSource(t, "Maildir")
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(time.Date(2019, 11, 20, 0, 0, 0, 0,
+			time.UTC), "", fas)
+		close(fas)
+	}()
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+	}
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	if len(res) != 3 {
+		t.Fatalf("expected failure and 2 articles, got %+v", res)
+	}
+
+	// Message with unparsable date is reported by its Message-ID.
+	if res[0].Err == nil || res[0].Article.URL != "mid:broken-1@example.com" {
+		t.Errorf("expected failure of broken message, got %+v", res[0])
+	}
+}
+
+func TestSource_Articles_Cursor(t *testing.T) {
+	s := newTestSource(t, "newsletters.mbox")
+
+	as, cur, err := s.Articles(time.Date(2019, 11, 19, 0, 0, 0, 0,
+		time.UTC), "")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 3 {
+		t.Fatalf("expected 3 articles, got %d", len(as))
+	}
+
+	as, cur, err = s.Articles(time.Date(2019, 11, 20, 0, 0, 0, 0,
+		time.UTC), cur)
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	if len(as) != 0 {
+		t.Errorf("expected no articles after cursor, got %d", len(as))
+	}
+
+	pos, err := decodePosition(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if _, exists := pos.IDs["old-1@example.com"]; exists {
+		t.Error("expected message older than from to be pruned")
+	}
+
+	if len(pos.IDs) != 2 {
+		t.Errorf("expected 2 IDs in cursor, got %v", pos.IDs)
+	}
+}
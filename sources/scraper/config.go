@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"errors"
+	"strings"
+)
+
+// Config declares how to scrape a site. ListURL may contain {year},
+// {month} and {day} placeholders, in which case one list page per day
+// is fetched. TimeFormat is a Go time layout; date parts missing in it,
+// like year of "02.01 15:04", are taken from the list page day.
+type Config struct {
+	ListURL        string `json:"listURL"`
+	ItemSelector   string `json:"itemSelector"`
+	LinkSelector   string `json:"linkSelector"`
+	TimeSelector   string `json:"timeSelector"`
+	HeaderSelector string `json:"headerSelector"`
+	BodySelector   string `json:"bodySelector"`
+	TimeFormat     string `json:"timeFormat"`
+	Timezone       string `json:"timezone"`
+
+	// PageHeaderSelector selects header on article page, it's used to
+	// notice header changes when articles are refetched.
+	PageHeaderSelector string `json:"pageHeaderSelector"`
+
+	// RedirectMeansEmpty makes redirected list pages count as pages
+	// without articles instead of being followed.
+	RedirectMeansEmpty bool `json:"redirectMeansEmpty"`
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.ListURL == "":
+		return errors.New("empty list URL")
+	case c.ItemSelector == "":
+		return errors.New("empty item selector")
+	case c.LinkSelector == "":
+		return errors.New("empty link selector")
+	case c.TimeSelector == "":
+		return errors.New("empty time selector")
+	case c.BodySelector == "":
+		return errors.New("empty body selector")
+	case c.TimeFormat == "":
+		return errors.New("empty time format")
+	}
+	return nil
+}
+
+func (c Config) daily() bool {
+	return strings.Contains(c.ListURL, "{day}")
+}
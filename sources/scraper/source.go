@@ -0,0 +1,508 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+	"github.com/dimuls/news-aggregator/sources/layout"
+)
+
+type Source struct {
+	name      string
+	config    Config
+	location  *time.Location
+	dateParts dateParts
+
+	fetcher   *fetcher.Fetcher
+	selectors layout.Counter
+	log       *logrus.Entry
+}
+
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	err := c.validate()
+	if err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+
+	loc := time.UTC
+
+	if c.Timezone != "" {
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, errors.New("failed to load timezone: " + err.Error())
+		}
+	}
+
+	return &Source{
+		name:      name,
+		config:    c,
+		location:  loc,
+		dateParts: layoutDateParts(c.TimeFormat),
+		fetcher:   f,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "scraper_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.location
+}
+
+// SelectorCounts returns counts of selector matches since the previous
+// call.
+func (s *Source) SelectorCounts() []entity.SelectorCount {
+	return s.selectors.Take()
+}
+
+func (s *Source) day(t time.Time) time.Time {
+	t = t.In(s.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas      = make(chan entity.FetchedArticle)
+		errs     = make(chan error, 1)
+		as       []entity.Article
+		nextCur  = cur
+		fetchErr error
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil && fetchErr == nil {
+			fetchErr = fa.Err
+		}
+		as = append(as, fa.Article)
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fetchErr != nil {
+		return nil, "", fetchErr
+	}
+
+	return as, nextCur, nil
+}
+
+// StreamArticles sends listed articles published not before from with
+// their texts to fas. Position is advanced over the successfully fetched
+// articles only, so the failed ones are fetched again next time.
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	as, failures, err := s.articlesFrom(pos.From(from), pos)
+	if err != nil {
+		return err
+	}
+
+	p := cursor.NewProgress(pos)
+
+	s.sendFailures(failures, p.Position().Encode(), fas)
+
+	for _, a := range as {
+		a.Text, a.ExtractionMethod, err = s.articleText(a.URL)
+		if err != nil {
+			err = errors.New("failed to get article text: " + err.Error())
+			p.Failed(a)
+		} else {
+			p.Fetched(a)
+		}
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// articlesFrom returns listed articles published not before from and not
+// seen according to pos, without texts, and failures of the list items.
+func (s *Source) articlesFrom(from time.Time, pos cursor.Position) (
+	[]entity.Article, []entity.FetchFailure, error) {
+
+	now := time.Now()
+
+	if from.After(now) {
+		return nil, nil, errors.New("from is after now")
+	}
+
+	var (
+		as       []entity.Article
+		failures []entity.FetchFailure
+	)
+
+	if !s.config.daily() {
+		currAs, currFailures, err := s.articles(s.config.ListURL, s.day(now))
+		if err != nil {
+			return nil, nil, errors.New("failed to get articles: " +
+				err.Error())
+		}
+		as, failures = currAs, currFailures
+	} else {
+		nowDay := s.day(now)
+
+		for day := s.day(from); !day.After(nowDay); day = day.AddDate(0, 0, 1) {
+			currAs, currFailures, err := s.articles(s.formListURL(day), day)
+			if err != nil {
+				return nil, nil, errors.New("failed to get articles: " +
+					err.Error())
+			}
+
+			as = append(as, currAs...)
+			failures = append(failures, currFailures...)
+		}
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	fromIndex := len(as)
+
+	for i, a := range as {
+		if !a.PublishedAt.Before(from) {
+			fromIndex = i
+			break
+		}
+	}
+
+	return pos.Filter(as[fromIndex:]), failures, nil
+}
+
+// DayArticles sends all articles of the day to fas. Only year, month and
+// day of the given day are used. It's supported by daily list URLs only.
+func (s *Source) DayArticles(day time.Time,
+	fas chan<- entity.FetchedArticle) error {
+
+	if !s.config.daily() {
+		return errors.New("list URL is not daily")
+	}
+
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0,
+		s.location)
+
+	as, failures, err := s.articles(s.formListURL(day), day)
+	if err != nil {
+		return errors.New("failed to get articles: " + err.Error())
+	}
+
+	s.sendFailures(failures, "", fas)
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	for _, a := range as {
+		a.Text, a.ExtractionMethod, err = s.articleText(a.URL)
+		if err != nil {
+			err = errors.New("failed to get article text: " + err.Error())
+		}
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// sendFailures sends list items failures to fas with the unchanged cursor.
+func (s *Source) sendFailures(failures []entity.FetchFailure, cur string,
+	fas chan<- entity.FetchedArticle) {
+
+	for _, f := range failures {
+		fas <- entity.FetchedArticle{
+			Article: entity.Article{
+				URL:        f.URL,
+				SourceName: s.name,
+			},
+			Cursor: cur,
+			Err:    errors.New(f.Reason),
+		}
+	}
+}
+
+func (s *Source) formListURL(day time.Time) string {
+	lURL := strings.Replace(s.config.ListURL, "{year}",
+		strconv.Itoa(day.Year()), 1)
+	lURL = strings.Replace(lURL, "{month}",
+		fmt.Sprintf("%02d", day.Month()), 1)
+	return strings.Replace(lURL, "{day}",
+		fmt.Sprintf("%02d", day.Day()), 1)
+}
+
+// dateParts tells which date parts time layout has.
+type dateParts struct {
+	year, month, day bool
+}
+
+// layoutDateParts finds date parts of layout by formatting a probe time
+// with it and parsing the result back.
+func layoutDateParts(layout string) dateParts {
+	probe := time.Date(1999, 12, 28, 0, 0, 0, 0, time.UTC)
+
+	t, err := time.Parse(layout, probe.Format(layout))
+	if err != nil {
+		return dateParts{}
+	}
+
+	return dateParts{
+		year:  t.Year() == probe.Year(),
+		month: t.Month() == probe.Month(),
+		day:   t.Day() == probe.Day(),
+	}
+}
+
+// parseTime parses time of the list page day, date parts missing in time
+// format are taken from day. Time without year which is far after day is
+// of the previous year, like December article on January list page.
+func (s *Source) parseTime(day time.Time, timeStr string) (time.Time, error) {
+	t, err := time.ParseInLocation(s.config.TimeFormat, timeStr, s.location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	dps := s.dateParts
+
+	if dps.year && dps.month && dps.day {
+		return t, nil
+	}
+
+	year, month, d := t.Date()
+
+	if !dps.year {
+		year = day.Year()
+	}
+	if !dps.month {
+		month = day.Month()
+	}
+	if !dps.day {
+		d = day.Day()
+	}
+
+	t = time.Date(year, month, d, t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond(), t.Location())
+
+	if !dps.year && t.After(day.AddDate(0, 6, 0)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+
+	return t, nil
+}
+
+// articles returns articles of the list page and failures of the list
+// items which couldn't be parsed.
+func (s *Source) articles(lURL string, day time.Time) (
+	[]entity.Article, []entity.FetchFailure, error) {
+
+	log := s.log.WithField("list_url", lURL)
+
+	base, err := url.Parse(lURL)
+	if err != nil {
+		return nil, nil, errors.New("failed to parse list URL: " + err.Error())
+	}
+
+	get := s.fetcher.Get
+	if s.config.RedirectMeansEmpty {
+		get = s.fetcher.GetNoRedirect
+	}
+
+	res, err := get(lURL)
+	if err != nil {
+		log.WithError(err).Error("failed to get list URL")
+		return nil, nil, errors.New("failed to HTTP get list URL: " + err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if s.config.RedirectMeansEmpty && res.StatusCode >= 300 &&
+			res.StatusCode < 400 {
+			return nil, nil, nil
+		}
+		log.WithField("status_code", res.StatusCode).
+			Error("get list returned not OK status code")
+		return nil, nil, errors.New("not OK status code")
+	}
+
+	body, err := res.DecodedBody()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, errors.New("failed to parse list HTML: " + err.Error())
+	}
+
+	var (
+		as       []entity.Article
+		failures []entity.FetchFailure
+	)
+
+	items := s.selectors.Find(doc.Selection, s.config.ItemSelector)
+
+	items.Each(func(i int, sel *goquery.Selection) {
+		link := s.selectors.Find(sel, s.config.LinkSelector)
+
+		href, hrefExists := link.Attr("href")
+		if !hrefExists {
+			failures = append(failures, entity.FetchFailure{
+				URL:    lURL,
+				Reason: fmt.Sprintf("failed to find URL of article #%d", i),
+			})
+			return
+		}
+
+		aURL, err := base.Parse(href)
+		if err != nil {
+			failures = append(failures, entity.FetchFailure{
+				URL:    href,
+				Reason: "failed to parse article URL: " + err.Error(),
+			})
+			return
+		}
+
+		timeStr := strings.TrimSpace(
+			s.selectors.Find(sel, s.config.TimeSelector).Text())
+
+		publishedAt, err := s.parseTime(day, timeStr)
+		if err != nil {
+			failures = append(failures, entity.FetchFailure{
+				URL:    aURL.String(),
+				Reason: "failed to parse time: " + err.Error(),
+			})
+			return
+		}
+
+		header := link.Text()
+		if s.config.HeaderSelector != "" {
+			header = s.selectors.Find(sel, s.config.HeaderSelector).Text()
+		}
+
+		as = append(as, entity.Article{
+			URL:         aURL.String(),
+			Header:      extract.Header(header),
+			PublishedAt: publishedAt,
+			SourceName:  s.name,
+		})
+	})
+
+	return as, failures, nil
+}
+
+func (s *Source) articleDoc(aURL string) (*goquery.Document, error) {
+	res, err := s.fetcher.Get(aURL)
+	if err != nil {
+		return nil, errors.New("failed to HTTP get article URL: " + err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("not OK status code")
+	}
+
+	body, err := res.DecodedBody()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("failed to parse article HTML: " + err.Error())
+	}
+
+	return doc, nil
+}
+
+func (s *Source) articleText(aURL string) (string, string, error) {
+	doc, err := s.articleDoc(aURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	text, method := s.docText(doc, &s.selectors)
+
+	return text, method, nil
+}
+
+// docText returns article text and its extraction method, body selector
+// matches are counted by sels. Unmatched body selector falls back to the
+// main content extraction which modifies doc.
+func (s *Source) docText(doc *goquery.Document,
+	sels *layout.Counter) (string, string) {
+
+	var ps []string
+
+	body := sels.Find(doc.Selection, s.config.BodySelector)
+
+	body.Each(func(i int, s *goquery.Selection) {
+		ps = append(ps,
+			strings.TrimSpace(html.UnescapeString(s.Text())))
+	})
+
+	return extract.WithFallback(doc, strings.Join(ps, "\n"))
+}
+
+// RefetchArticle fetches article page again and returns a with actual
+// text. Header is updated only when page header selector is configured.
+// Selector matches of refetches aren't counted, so layout drift is
+// detected by the regular fetches only.
+func (s *Source) RefetchArticle(a entity.Article) (entity.Article, error) {
+	doc, err := s.articleDoc(a.URL)
+	if err != nil {
+		return entity.Article{}, err
+	}
+
+	if s.config.PageHeaderSelector != "" {
+		header := extract.Header(
+			doc.Find(s.config.PageHeaderSelector).Text())
+		if header != "" {
+			a.Header = header
+		}
+	}
+
+	var sels layout.Counter
+
+	a.Text, a.ExtractionMethod = s.docText(doc, &sels)
+
+	return a, nil
+}
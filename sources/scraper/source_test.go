@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+)
+
+func newTestSource(t *testing.T, listURL string) *Source {
+	tr, err := replay.NewTransport(filepath.Join("testdata", "news.json"),
+		replay.Replay)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: true,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("example", Config{
+		ListURL:            listURL,
+		ItemSelector:       ".news .item",
+		LinkSelector:       "a",
+		TimeSelector:       ".time",
+		BodySelector:       ".article-body p",
+		TimeFormat:         "15:04",
+		Timezone:           "Europe/Moscow",
+		PageHeaderSelector: "h1.title",
+	}, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	return s
+}
+
+const dailyListURL = "https://news.example.com/news/{year}/{month}/{day}/"
+
+func TestSource_DayArticles(t *testing.T) {
+	s := newTestSource(t, dailyListURL)
+
+	fas := make(chan entity.FetchedArticle, 10)
+
+	err := s.DayArticles(time.Date(2019, 11, 20, 0, 0, 0, 0, time.UTC), fas)
+	if err != nil {
+		t.Fatalf("failed to get day articles: %v", err)
+	}
+
+	close(fas)
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+	}
+
+	if len(res) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(res))
+	}
+
+	weather, metro := res[0], res[1]
+
+	if weather.Err == nil ||
+		weather.Article.Header != "Тепло в Москве" ||
+		!weather.Article.PublishedAt.Equal(
+			time.Date(2019, 11, 20, 5, 15, 0, 0, time.UTC)) {
+		t.Errorf("expected failed weather article, got %+v: %v",
+			weather.Article, weather.Err)
+	}
+
+	if metro.Err != nil {
+		t.Fatalf("failed to fetch metro article: %v", metro.Err)
+	}
+
+	a := metro.Article
+
+	if a.URL != "https://news.example.com/news/2019/11/20/metro/" ||
+		a.Header != "Открыта станция «Лесная»" ||
+		a.Text != "В Москве открылась новая станция метро.\n"+
+			"Она станет частью новой линии." ||
+		a.ExtractionMethod != extract.MethodSelector ||
+		a.SourceName != "example" {
+		t.Errorf("unexpected metro article %+v", a)
+	}
+
+	if !a.PublishedAt.Equal(time.Date(2019, 11, 20, 6, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected metro publish time %v", a.PublishedAt)
+	}
+
+	// Refetch of unchanged page gives the same content hash.
+	ra, err := s.RefetchArticle(a)
+	if err != nil {
+		t.Fatalf("failed to refetch article: %v", err)
+	}
+
+	if ra.ContentHash() != a.ContentHash() {
+		t.Errorf("expected unchanged refetched article, got %+v", ra)
+	}
+
+	if counts := s.SelectorCounts(); len(counts) == 0 {
+		t.Error("expected selector counts of the fetch")
+	}
+}
+
+func TestSource_StreamArticles(t *testing.T) {
+	s := newTestSource(t, "https://news.example.com/latest/")
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(time.Now().AddDate(0, 0, -2), "", fas)
+		close(fas)
+	}()
+
+	var (
+		res []entity.FetchedArticle
+		cur string
+	)
+
+	for fa := range fas {
+		res = append(res, fa)
+		cur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	// Failed list items and article don't stop the run.
+	if len(res) != 4 {
+		t.Fatalf("expected 2 failed list items and 2 articles, got %+v", res)
+	}
+
+	noLink, badTime := res[0], res[1]
+
+	if noLink.Err == nil || noLink.Article.URL !=
+		"https://news.example.com/latest/" {
+		t.Errorf("expected list item without link failure, got %+v",
+			noLink)
+	}
+
+	if badTime.Err == nil || badTime.Article.URL !=
+		"https://news.example.com/news/2019/11/20/soon/" {
+		t.Errorf("expected list item with bad time failure, got %+v",
+			badTime)
+	}
+
+	weather, metro := res[2], res[3]
+
+	if weather.Err == nil || metro.Err != nil {
+		t.Fatalf("expected failed weather and fetched metro articles, "+
+			"got %+v and %+v", weather, metro)
+	}
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if pos.Seen(weather.Article) {
+		t.Error("expected cursor not to pass failed article")
+	}
+}
+
+func TestSource_parseTime(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		format   string
+		value    string
+		day      time.Time
+		expected time.Time
+	}{
+		{"15:04", "09:30",
+			time.Date(2019, 11, 20, 0, 0, 0, 0, msk),
+			time.Date(2019, 11, 20, 9, 30, 0, 0, msk)},
+		// Day and month of the time are kept.
+		{"02.01 15:04", "19.11 23:50",
+			time.Date(2019, 11, 20, 0, 0, 0, 0, msk),
+			time.Date(2019, 11, 19, 23, 50, 0, 0, msk)},
+		{"02.01 15:04", "31.12 23:50",
+			time.Date(2020, 1, 1, 0, 0, 0, 0, msk),
+			time.Date(2019, 12, 31, 23, 50, 0, 0, msk)},
+		{"02.01.2006 15:04", "18.11.2019 10:00",
+			time.Date(2019, 11, 20, 0, 0, 0, 0, msk),
+			time.Date(2019, 11, 18, 10, 0, 0, 0, msk)},
+		// Parsed offset is kept.
+		{"Jan 2 15:04 -0700", "Nov 19 23:50 +0000",
+			time.Date(2019, 11, 20, 0, 0, 0, 0, msk),
+			time.Date(2019, 11, 19, 23, 50, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		s := &Source{
+			config:    Config{TimeFormat: test.format},
+			location:  msk,
+			dateParts: layoutDateParts(test.format),
+		}
+
+		actual, err := s.parseTime(test.day, test.value)
+		if err != nil {
+			t.Errorf("%s %s: failed to parse: %v", test.format, test.value,
+				err)
+			continue
+		}
+
+		if !actual.Equal(test.expected) {
+			t.Errorf("%s %s: expected %v, got %v", test.format,
+				test.value, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// maxUnzippedSize limits size of gzipped sitemap, the protocol limits
+// sitemaps to 50MB.
+const maxUnzippedSize = 50 << 20
+
+// document is either sitemap index or URL set. The news extension
+// elements are matched by their local names.
+type document struct {
+	XMLName  xml.Name
+	URLs     []entry `xml:"url"`
+	Sitemaps []entry `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+	News    struct {
+		PublicationDate string `xml:"publication_date"`
+		Title           string `xml:"title"`
+	} `xml:"news"`
+}
+
+func parseSitemap(data []byte) (document, error) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return document{}, errors.New("failed to open gzip: " +
+				err.Error())
+		}
+
+		data, err = ioutil.ReadAll(io.LimitReader(zr, maxUnzippedSize))
+		if err != nil {
+			return document{}, errors.New("failed to gunzip: " + err.Error())
+		}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = charset.NewReaderLabel
+
+	var doc document
+
+	err := d.Decode(&doc)
+	if err != nil {
+		return document{}, err
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return document{}, errors.New("unexpected root element " +
+			doc.XMLName.Local)
+	}
+
+	return doc, nil
+}
+
+// W3C datetime layouts used by sitemaps.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses W3C datetime, dates without offset are in loc.
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	for _, l := range dateLayouts {
+		t, err := time.ParseInLocation(l, s, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("unknown date format")
+}
@@ -0,0 +1,152 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+const urlSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+	xmlns:news="http://www.google.com/schemas/sitemap-news/0.9">
+	<url>
+		<loc>https://news.example.com/a</loc>
+		<lastmod>2019-11-20T12:00:00+03:00</lastmod>
+		<news:news>
+			<news:publication_date>2019-11-20T10:00:00+03:00</news:publication_date>
+			<news:title>Заголовок</news:title>
+		</news:news>
+	</url>
+	<url>
+		<loc>https://news.example.com/b</loc>
+		<lastmod>2019-11-19</lastmod>
+	</url>
+</urlset>`
+
+const sitemapIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap>
+		<loc>https://news.example.com/news.xml</loc>
+		<lastmod>2019-11-20T12:00:00Z</lastmod>
+	</sitemap>
+	<sitemap>
+		<loc>/archive.xml.gz</loc>
+	</sitemap>
+</sitemapindex>`
+
+func TestParseSitemap_URLSet(t *testing.T) {
+	doc, err := parseSitemap([]byte(urlSet))
+	if err != nil {
+		t.Fatalf("failed to parse sitemap: %v", err)
+	}
+
+	if len(doc.URLs) != 2 || len(doc.Sitemaps) != 0 {
+		t.Fatalf("expected 2 URLs and no sitemaps, got %d and %d",
+			len(doc.URLs), len(doc.Sitemaps))
+	}
+
+	e := doc.URLs[0]
+
+	if e.Loc != "https://news.example.com/a" {
+		t.Errorf("unexpected loc %s", e.Loc)
+	}
+
+	if e.News.PublicationDate != "2019-11-20T10:00:00+03:00" {
+		t.Errorf("unexpected publication date %s", e.News.PublicationDate)
+	}
+
+	if e.News.Title != "Заголовок" {
+		t.Errorf("unexpected title %s", e.News.Title)
+	}
+
+	if doc.URLs[1].LastMod != "2019-11-19" {
+		t.Errorf("unexpected lastmod %s", doc.URLs[1].LastMod)
+	}
+}
+
+func TestParseSitemap_Index(t *testing.T) {
+	doc, err := parseSitemap([]byte(sitemapIndex))
+	if err != nil {
+		t.Fatalf("failed to parse sitemap index: %v", err)
+	}
+
+	if len(doc.Sitemaps) != 2 || len(doc.URLs) != 0 {
+		t.Fatalf("expected 2 sitemaps and no URLs, got %d and %d",
+			len(doc.Sitemaps), len(doc.URLs))
+	}
+
+	if doc.Sitemaps[1].Loc != "/archive.xml.gz" {
+		t.Errorf("unexpected loc %s", doc.Sitemaps[1].Loc)
+	}
+}
+
+func TestParseSitemap_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(urlSet))
+	zw.Close()
+
+	doc, err := parseSitemap(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse gzipped sitemap: %v", err)
+	}
+
+	if len(doc.URLs) != 2 {
+		t.Errorf("expected 2 URLs, got %d", len(doc.URLs))
+	}
+}
+
+func TestParseSitemap_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`<html><body>Not found</body></html>`,
+		`not XML`,
+	} {
+		_, err := parseSitemap([]byte(data))
+		if err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{"2019-11-20T10:00:00+03:00",
+			time.Date(2019, 11, 20, 7, 0, 0, 0, time.UTC)},
+		{"2019-11-20T10:00:00.123Z",
+			time.Date(2019, 11, 20, 10, 0, 0, 123e6, time.UTC)},
+		{"2019-11-20T10:00+01:00",
+			time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC)},
+		{"2019-11-20T10:00:00",
+			time.Date(2019, 11, 20, 10, 0, 0, 0, moscow)},
+		{" 2019-11-20 ",
+			time.Date(2019, 11, 20, 0, 0, 0, 0, moscow)},
+	}
+
+	for _, test := range tests {
+		got, err := parseDate(test.date, moscow)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", test.date, err)
+			continue
+		}
+
+		if !got.Equal(test.want) {
+			t.Errorf("expected %q to be parsed as %v, got %v", test.date,
+				test.want, got)
+		}
+	}
+
+	_, err = parseDate("20 Nov 2019", moscow)
+	if err == nil {
+		t.Error("expected error for unknown date format")
+	}
+}
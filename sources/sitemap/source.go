@@ -0,0 +1,344 @@
+// Package sitemap implements source which discovers articles by site
+// sitemaps. Sitemap indexes are read recursively, URLs are filtered by
+// news:publication_date or lastmod and article texts are extracted from
+// the pages by body selector or by the generic main content extractor.
+package sitemap
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+	"github.com/dimuls/news-aggregator/sources/layout"
+	"github.com/dimuls/news-aggregator/sources/link"
+)
+
+// Config declares sitemap source. URL is sitemap or sitemap index URL.
+// Empty BodySelector makes the main content extractor used for all pages.
+// Header is taken from news:title, HeaderSelector on the page, og:title
+// meta or page title in that order. Dates without offset are in Timezone,
+// UTC by default.
+type Config struct {
+	URL            string `json:"url"`
+	BodySelector   string `json:"bodySelector"`
+	HeaderSelector string `json:"headerSelector"`
+	Timezone       string `json:"timezone"`
+	MaxDepth       int    `json:"maxDepth"`
+}
+
+const defaultMaxDepth = 3
+
+type Source struct {
+	name     string
+	config   Config
+	location *time.Location
+
+	fetcher   *fetcher.Fetcher
+	selectors layout.Counter
+	log       *logrus.Entry
+}
+
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, errors.New("failed to parse sitemap URL: " + err.Error())
+	}
+
+	if !u.IsAbs() {
+		return nil, errors.New("sitemap URL is not absolute")
+	}
+
+	if c.MaxDepth < 0 {
+		return nil, errors.New("negative max depth")
+	}
+
+	if c.MaxDepth == 0 {
+		c.MaxDepth = defaultMaxDepth
+	}
+
+	loc := time.UTC
+
+	if c.Timezone != "" {
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, errors.New("failed to load timezone: " + err.Error())
+		}
+	}
+
+	return &Source{
+		name:     name,
+		config:   c,
+		location: loc,
+		fetcher:  f,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "sitemap_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.location
+}
+
+// SelectorCounts returns counts of selector matches since the previous
+// call.
+func (s *Source) SelectorCounts() []entity.SelectorCount {
+	return s.selectors.Take()
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas      = make(chan entity.FetchedArticle)
+		errs     = make(chan error, 1)
+		as       []entity.Article
+		nextCur  = cur
+		fetchErr error
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil && fetchErr == nil {
+			fetchErr = fa.Err
+		}
+		as = append(as, fa.Article)
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fetchErr != nil {
+		return nil, "", fetchErr
+	}
+
+	return as, nextCur, nil
+}
+
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	from = pos.From(from)
+
+	as, err := s.articles(from)
+	if err != nil {
+		return err
+	}
+
+	p := cursor.NewProgress(pos)
+
+	for _, a := range pos.Filter(as) {
+		a.Header, a.Text, a.ExtractionMethod, err = s.page(a.URL, a.Header)
+		if err != nil {
+			err = errors.New("failed to get article page: " + err.Error())
+			p.Failed(a)
+		} else {
+			p.Fetched(a)
+		}
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// articles returns articles of sitemap URLs published not before from
+// without texts. URLs without dates are skipped.
+func (s *Source) articles(from time.Time) ([]entity.Article, error) {
+	var (
+		as      []entity.Article
+		visited = map[string]struct{}{}
+	)
+
+	err := s.walk(s.config.URL, from, 0, visited, func(e entry) {
+		date := e.News.PublicationDate
+		if date == "" {
+			date = e.LastMod
+		}
+
+		if date == "" {
+			return
+		}
+
+		publishedAt, err := parseDate(date, s.location)
+		if err != nil {
+			s.log.WithError(err).WithField("url", e.Loc).
+				Warning("failed to parse URL date, skipping")
+			return
+		}
+
+		if publishedAt.Before(from) {
+			return
+		}
+
+		as = append(as, entity.Article{
+			URL:         strings.TrimSpace(e.Loc),
+			Header:      strings.TrimSpace(e.News.Title),
+			PublishedAt: publishedAt,
+			SourceName:  s.name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	return as, nil
+}
+
+// walk calls f for the URL entries of sitemap and of the sitemaps it
+// indexes. Indexed sitemaps modified before from are skipped, the failed
+// ones are logged and skipped too, so a single broken sitemap doesn't stop
+// the source.
+func (s *Source) walk(smURL string, from time.Time, depth int,
+	visited map[string]struct{}, f func(e entry)) error {
+
+	if _, exists := visited[smURL]; exists {
+		return nil
+	}
+
+	visited[smURL] = struct{}{}
+
+	log := s.log.WithField("sitemap_url", smURL)
+
+	res, err := s.fetcher.Get(smURL)
+	if err != nil {
+		log.WithError(err).Error("failed to get sitemap URL")
+		return errors.New("failed to HTTP get sitemap URL: " + err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		log.WithField("status_code", res.StatusCode).
+			Error("get sitemap returned not OK status code")
+		return errors.New("not OK status code")
+	}
+
+	doc, err := parseSitemap(res.Body)
+	if err != nil {
+		return errors.New("failed to parse sitemap " + smURL + ": " +
+			err.Error())
+	}
+
+	for _, e := range doc.URLs {
+		f(e)
+	}
+
+	if len(doc.Sitemaps) > 0 && depth >= s.config.MaxDepth {
+		log.Warning("max sitemap depth reached, skipping indexed sitemaps")
+		return nil
+	}
+
+	for _, sm := range doc.Sitemaps {
+		if sm.LastMod != "" {
+			lastMod, err := parseDate(sm.LastMod, s.location)
+			if err == nil && lastMod.Before(from) {
+				continue
+			}
+		}
+
+		childURL, err := link.Resolve(smURL, strings.TrimSpace(sm.Loc))
+		if err == nil {
+			err = s.walk(childURL, from, depth+1, visited, f)
+		}
+		if err != nil {
+			log.WithError(err).WithField("child_sitemap_url", sm.Loc).
+				Warning("failed to walk indexed sitemap, skipping")
+		}
+	}
+
+	return nil
+}
+
+// page fetches article page and returns its header, text and text
+// extraction method. Header is kept when not empty.
+func (s *Source) page(aURL, header string) (string, string, string, error) {
+	res, err := s.fetcher.Get(aURL)
+	if err != nil {
+		return "", "", "", errors.New("failed to HTTP get article URL: " +
+			err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", "", errors.New("not OK status code")
+	}
+
+	body, err := res.DecodedBody()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", "", "", errors.New("failed to parse article HTML: " +
+			err.Error())
+	}
+
+	if header == "" {
+		header = s.pageHeader(doc)
+	}
+
+	var text string
+
+	if s.config.BodySelector != "" {
+		text = extract.SelectionText(
+			s.selectors.Find(doc.Selection, s.config.BodySelector))
+	}
+
+	text, method := extract.WithFallback(doc, text)
+
+	return header, text, method, nil
+}
+
+func (s *Source) pageHeader(doc *goquery.Document) string {
+	if s.config.HeaderSelector != "" {
+		header := strings.TrimSpace(
+			s.selectors.Find(doc.Selection, s.config.HeaderSelector).Text())
+		if header != "" {
+			return header
+		}
+	}
+
+	if og, exists := doc.Find(`meta[property="og:title"]`).
+		Attr("content"); exists && strings.TrimSpace(og) != "" {
+		return strings.TrimSpace(og)
+	}
+
+	return strings.TrimSpace(doc.Find("title").First().Text())
+}
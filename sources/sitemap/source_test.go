@@ -0,0 +1,146 @@
+package sitemap
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+)
+
+func newTestSource(t *testing.T) *Source {
+	tr, err := replay.NewTransport(filepath.Join("testdata", "sitemap.json"),
+		replay.Replay)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: true,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("example", Config{
+		URL:          "https://news.example.com/sitemap_index.xml",
+		BodySelector: "article .content p",
+		Timezone:     "Europe/Moscow",
+	}, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	return s
+}
+
+func stream(t *testing.T, s *Source, from time.Time, cur string) (
+	[]entity.FetchedArticle, string) {
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+		cur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	return res, cur
+}
+
+func TestSource_StreamArticles(t *testing.T) {
+	s := newTestSource(t)
+
+	from := time.Date(2019, 11, 19, 0, 0, 0, 0, s.location)
+
+	fas, cur := stream(t, s, from, "")
+
+	if len(fas) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(fas))
+	}
+
+	weather, metro := fas[0], fas[1]
+
+	if weather.Article.URL != "https://news.example.com/weather" ||
+		weather.Err == nil {
+		t.Errorf("expected failed weather article first, got %s: %v",
+			weather.Article.URL, weather.Err)
+	}
+
+	if metro.Err != nil {
+		t.Fatalf("failed to fetch metro article: %v", metro.Err)
+	}
+
+	a := metro.Article
+
+	if !a.PublishedAt.Equal(time.Date(2019, 11, 20, 6, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected metro publish time %v", a.PublishedAt)
+	}
+
+	if a.Header != "Открыта новая станция метро" ||
+		a.Text != "В Москве открылась новая станция метро." ||
+		a.ExtractionMethod != extract.MethodSelector ||
+		a.SourceName != "example" {
+		t.Errorf("unexpected metro article %+v", a)
+	}
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if pos.Seen(weather.Article) || pos.Seen(metro.Article) {
+		t.Error("expected cursor not to pass failed article")
+	}
+
+	fas, cur = stream(t, s, from, cur)
+
+	if len(fas) != 2 {
+		t.Fatalf("expected 2 articles on retry, got %d", len(fas))
+	}
+
+	for _, fa := range fas {
+		if fa.Err != nil {
+			t.Errorf("failed to fetch %s on retry: %v", fa.Article.URL,
+				fa.Err)
+		}
+	}
+
+	if fas[0].Article.Header != "В Москве ожидается тепло" {
+		t.Errorf("expected header from page title, got %s",
+			fas[0].Article.Header)
+	}
+
+	pos, err = cursor.Decode(cur)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+
+	if !pos.Seen(fas[0].Article) || !pos.Seen(fas[1].Article) {
+		t.Error("expected cursor to pass retried articles")
+	}
+
+	fas, _ = stream(t, s, from, cur)
+
+	if len(fas) != 0 {
+		t.Errorf("expected no articles after cursor, got %d", len(fas))
+	}
+}
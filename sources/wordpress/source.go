@@ -0,0 +1,373 @@
+// Package wordpress implements source of sites running WordPress. Posts
+// are paged through the /wp-json/wp/v2/posts REST API endpoint in publish
+// order, their categories and tags are read from the embedded terms.
+package wordpress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/extract"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/sources/cursor"
+)
+
+// Config declares WordPress source. URL is the site URL, posts endpoint is
+// resolved against it. Timezone should match the site timezone setting,
+// since WordPress compares after and before params with posts local time,
+// it's UTC by default.
+type Config struct {
+	URL      string `json:"url"`
+	PerPage  int    `json:"perPage"`
+	Timezone string `json:"timezone"`
+}
+
+const (
+	postsPath      = "/wp-json/wp/v2/posts"
+	dateLayout     = "2006-01-02T15:04:05"
+	defaultPerPage = 100
+	maxPerPage     = 100
+	maxPages       = 100
+)
+
+func (c Config) validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("empty URL")
+	case c.PerPage < 0 || c.PerPage > maxPerPage:
+		return fmt.Errorf("per page should be between 1 and %d", maxPerPage)
+	}
+	return nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.PerPage == 0 {
+		c.PerPage = defaultPerPage
+	}
+	return c
+}
+
+type Source struct {
+	name     string
+	postsURL string
+	perPage  int
+	location *time.Location
+
+	fetcher *fetcher.Fetcher
+	log     *logrus.Entry
+}
+
+func NewSource(name string, c Config, f *fetcher.Fetcher) (*Source, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+
+	err := c.validate()
+	if err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+
+	c = c.withDefaults()
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, errors.New("failed to parse URL: " + err.Error())
+	}
+
+	if !u.IsAbs() {
+		return nil, errors.New("URL is not absolute")
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + postsPath
+
+	loc := time.UTC
+
+	if c.Timezone != "" {
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, errors.New("failed to load timezone: " + err.Error())
+		}
+	}
+
+	return &Source{
+		name:     name,
+		postsURL: u.String(),
+		perPage:  c.PerPage,
+		location: loc,
+		fetcher:  f,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":   "wordpress_source",
+			"source_name": name,
+		}),
+	}, nil
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+func (s *Source) Location() *time.Location {
+	return s.location
+}
+
+func (s *Source) Articles(from time.Time, cur string) (
+	[]entity.Article, string, error) {
+
+	var (
+		fas     = make(chan entity.FetchedArticle)
+		errs    = make(chan error, 1)
+		as      []entity.Article
+		nextCur = cur
+	)
+
+	go func() {
+		errs <- s.StreamArticles(from, cur, fas)
+		close(fas)
+	}()
+
+	for fa := range fas {
+		if fa.Err != nil {
+			s.log.WithError(fa.Err).WithField("url", fa.Article.URL).
+				Warning("failed to fetch post, skipping")
+		} else {
+			as = append(as, fa.Article)
+		}
+		nextCur = fa.Cursor
+	}
+
+	err := <-errs
+	if err != nil {
+		return nil, "", err
+	}
+
+	return as, nextCur, nil
+}
+
+// StreamArticles sends not seen posts published not before from to fas.
+// Posts which failed to map are sent as failures, they aren't fetched
+// again.
+func (s *Source) StreamArticles(from time.Time, cur string,
+	fas chan<- entity.FetchedArticle) error {
+
+	pos, err := cursor.Decode(cur)
+	if err != nil {
+		return err
+	}
+
+	from = pos.From(from)
+
+	// After param is exclusive, so it's moved back to not miss the posts
+	// published exactly at from.
+	as, failures, err := s.articles(from.Add(-time.Second), time.Time{})
+	if err != nil {
+		return err
+	}
+
+	p := cursor.NewProgress(pos)
+
+	s.sendFailures(failures, p.Position().Encode(), fas)
+
+	for _, a := range as {
+		if a.PublishedAt.Before(from) || pos.Seen(a) {
+			continue
+		}
+
+		p.Fetched(a)
+
+		fas <- entity.FetchedArticle{
+			Article: a,
+			Cursor:  p.Position().Encode(),
+		}
+	}
+
+	return nil
+}
+
+// DayArticles sends all posts of the day to fas. Only year, month and day
+// of the given day are used.
+func (s *Source) DayArticles(day time.Time,
+	fas chan<- entity.FetchedArticle) error {
+
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0,
+		s.location)
+
+	as, failures, err := s.articles(day.Add(-time.Second),
+		day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	s.sendFailures(failures, "", fas)
+
+	for _, a := range as {
+		fas <- entity.FetchedArticle{Article: a}
+	}
+
+	return nil
+}
+
+// sendFailures sends failures of the posts mapping to fas with the
+// unchanged cursor.
+func (s *Source) sendFailures(failures []entity.FetchFailure, cur string,
+	fas chan<- entity.FetchedArticle) {
+
+	for _, f := range failures {
+		fas <- entity.FetchedArticle{
+			Article: entity.Article{
+				URL:        f.URL,
+				SourceName: s.name,
+			},
+			Cursor: cur,
+			Err:    errors.New(f.Reason),
+		}
+	}
+}
+
+type post struct {
+	Link    string `json:"link"`
+	DateGMT string `json:"date_gmt"`
+	Title   struct {
+		Rendered string `json:"rendered"`
+	} `json:"title"`
+	Content struct {
+		Rendered string `json:"rendered"`
+	} `json:"content"`
+	Embedded struct {
+		Terms [][]term `json:"wp:term"`
+	} `json:"_embedded"`
+}
+
+type term struct {
+	Name     string `json:"name"`
+	Taxonomy string `json:"taxonomy"`
+}
+
+func (s *Source) formPostsURL(after, before time.Time, page int) string {
+	q := url.Values{}
+
+	q.Set("after", after.In(s.location).Format(dateLayout))
+	if !before.IsZero() {
+		q.Set("before", before.In(s.location).Format(dateLayout))
+	}
+	q.Set("orderby", "date")
+	q.Set("order", "asc")
+	q.Set("per_page", strconv.Itoa(s.perPage))
+	q.Set("page", strconv.Itoa(page))
+	q.Set("_embed", "wp:term")
+
+	return s.postsURL + "?" + q.Encode()
+}
+
+// articles returns posts published after after and before before, zero
+// before means no upper limit, and failures of the posts which couldn't be
+// mapped.
+func (s *Source) articles(after, before time.Time) (
+	[]entity.Article, []entity.FetchFailure, error) {
+
+	var (
+		as       []entity.Article
+		failures []entity.FetchFailure
+	)
+
+	for page := 1; page <= maxPages; page++ {
+		pURL := s.formPostsURL(after, before, page)
+
+		log := s.log.WithField("posts_url", pURL)
+
+		res, err := s.fetcher.Get(pURL)
+		if err != nil {
+			log.WithError(err).Error("failed to get posts URL")
+			return nil, nil, errors.New("failed to HTTP get posts URL: " +
+				err.Error())
+		}
+
+		if res.StatusCode != http.StatusOK {
+			log.WithField("status_code", res.StatusCode).
+				Error("get posts returned not OK status code")
+			return nil, nil, errors.New("not OK status code")
+		}
+
+		var ps []post
+
+		err = json.Unmarshal(res.Body, &ps)
+		if err != nil {
+			return nil, nil, errors.New("failed to decode posts: " + err.Error())
+		}
+
+		for _, p := range ps {
+			a, err := s.article(p)
+			if err != nil {
+				fURL := p.Link
+				if fURL == "" {
+					fURL = pURL
+				}
+				failures = append(failures, entity.FetchFailure{
+					URL:    fURL,
+					Reason: "failed to map post: " + err.Error(),
+				})
+				continue
+			}
+			as = append(as, a)
+		}
+
+		if len(ps) < s.perPage {
+			break
+		}
+
+		// Total pages header may be stripped by proxies, paging stops on
+		// a short page then.
+		totalPages, err := strconv.Atoi(res.Header.Get("X-WP-TotalPages"))
+		if err == nil && page >= totalPages {
+			break
+		}
+	}
+
+	sort.Sort(entity.ArticlesByPublishedAt(as))
+
+	return as, failures, nil
+}
+
+func (s *Source) article(p post) (entity.Article, error) {
+	if p.Link == "" {
+		return entity.Article{}, errors.New("empty link")
+	}
+
+	publishedAt, err := time.ParseInLocation(dateLayout, p.DateGMT, time.UTC)
+	if err != nil {
+		return entity.Article{}, errors.New("failed to parse date: " +
+			err.Error())
+	}
+
+	a := entity.Article{
+		URL:         p.Link,
+		Header:      extract.HTMLText(p.Title.Rendered),
+		PublishedAt: publishedAt,
+		Text:        extract.HTMLText(p.Content.Rendered),
+		SourceName:  s.name,
+	}
+
+	for _, ts := range p.Embedded.Terms {
+		for _, t := range ts {
+			name := html.UnescapeString(t.Name)
+			switch t.Taxonomy {
+			case "category":
+				a.Categories = append(a.Categories, name)
+			case "post_tag":
+				a.Tags = append(a.Tags, name)
+			}
+		}
+	}
+
+	return a, nil
+}
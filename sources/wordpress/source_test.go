@@ -0,0 +1,170 @@
+package wordpress
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+	"github.com/dimuls/news-aggregator/fetcher"
+	"github.com/dimuls/news-aggregator/fetcher/replay"
+)
+
+func newTestSource(t *testing.T) *Source {
+	tr, err := replay.NewTransport(filepath.Join("testdata", "posts.json"),
+		replay.Replay)
+	if err != nil {
+		t.Fatalf("failed to create replay transport: %v", err)
+	}
+
+	f, err := fetcher.NewFetcher(fetcher.Config{
+		Transport:    tr,
+		IgnoreRobots: true,
+		RateLimit:    100,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	s, err := NewSource("example", Config{
+		URL:      "https://blog.example.com/",
+		PerPage:  2,
+		Timezone: "Europe/Moscow",
+	}, f)
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	return s
+}
+
+func urls(as []entity.Article) []string {
+	var us []string
+	for _, a := range as {
+		us = append(us, a.URL)
+	}
+	return us
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSource_Articles(t *testing.T) {
+	s := newTestSource(t)
+
+	// Paging stops at X-WP-TotalPages, the third page isn't recorded.
+	as, _, err := s.Articles(time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC),
+		"")
+	if err != nil {
+		t.Fatalf("failed to get articles: %v", err)
+	}
+
+	expectedURLs := []string{
+		"https://blog.example.com/1/",
+		"https://blog.example.com/2/",
+		"https://blog.example.com/3/",
+	}
+
+	if !equalStrings(urls(as), expectedURLs) {
+		t.Fatalf("expected articles %q, got %q", expectedURLs, urls(as))
+	}
+
+	a := as[0]
+
+	if !a.PublishedAt.Equal(time.Date(2019, 11, 20, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected publish time %v", a.PublishedAt)
+	}
+
+	if a.Header != "Заголовок — с тире" ||
+		a.Text != "Текст записи 1." ||
+		a.SourceName != "example" {
+		t.Errorf("unexpected article %+v", a)
+	}
+
+	expectedCategories := []string{"Новости", "Город & область"}
+
+	if !equalStrings(a.Categories, expectedCategories) {
+		t.Errorf("expected categories %q, got %q", expectedCategories,
+			a.Categories)
+	}
+
+	if !equalStrings(a.Tags, []string{"метро"}) {
+		t.Errorf("expected tags [метро], got %q", a.Tags)
+	}
+
+	if len(as[1].Categories) != 0 || len(as[1].Tags) != 0 {
+		t.Errorf("expected no terms, got %+v", as[1])
+	}
+}
+
+func TestSource_StreamArticles(t *testing.T) {
+	s := newTestSource(t)
+
+	fas := make(chan entity.FetchedArticle)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.StreamArticles(time.Date(2019, 11, 20, 9, 0, 0, 0,
+			time.UTC), "", fas)
+		close(fas)
+	}()
+
+	var res []entity.FetchedArticle
+
+	for fa := range fas {
+		res = append(res, fa)
+	}
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("failed to stream articles: %v", err)
+	}
+
+	// Post without link is reported with the posts page URL.
+	if len(res) != 4 || res[0].Err == nil ||
+		!strings.Contains(res[0].Article.URL, "page=2") {
+		t.Fatalf("expected failure of post without link first, got %+v",
+			res)
+	}
+}
+
+func TestSource_DayArticles(t *testing.T) {
+	s := newTestSource(t)
+
+	// Day bounds are formatted in the site timezone. Unparsable
+	// X-WP-TotalPages is ignored, paging stops on the short page.
+	fas := make(chan entity.FetchedArticle, 10)
+
+	err := s.DayArticles(time.Date(2019, 11, 21, 0, 0, 0, 0, time.UTC), fas)
+	if err != nil {
+		t.Fatalf("failed to get day articles: %v", err)
+	}
+
+	close(fas)
+
+	var as []entity.Article
+
+	for fa := range fas {
+		as = append(as, fa.Article)
+	}
+
+	expectedURLs := []string{
+		"https://blog.example.com/4/",
+		"https://blog.example.com/5/",
+		"https://blog.example.com/6/",
+	}
+
+	if !equalStrings(urls(as), expectedURLs) {
+		t.Errorf("expected articles %q, got %q", expectedURLs, urls(as))
+	}
+}
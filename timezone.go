@@ -0,0 +1,47 @@
+package newsaggregator
+
+import (
+	"time"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+// LocatedSource declares timezone of its site. Article times are kept
+// with offset of this timezone even when source returns them in another
+// one.
+type LocatedSource interface {
+	Source
+	Location() *time.Location
+}
+
+func (na *NewsAggregator) sourceLocation(sourceName string) *time.Location {
+	for _, s := range na.sources {
+		if s.Name() != sourceName {
+			continue
+		}
+		if ls, isLocated := s.(LocatedSource); isLocated {
+			return ls.Location()
+		}
+		break
+	}
+	return nil
+}
+
+// normalizeTimes converts article publish and modification times to UTC
+// keeping original publish time offsets. When loc isn't nil the offset of
+// loc is kept.
+func normalizeTimes(as []entity.Article, loc *time.Location) {
+	for i := range as {
+		t := as[i].PublishedAt
+		if loc != nil {
+			t = t.In(loc)
+		}
+		_, as[i].PublishedAtOffset = t.Zone()
+		as[i].PublishedAt = t.UTC()
+
+		if as[i].ModifiedAt != nil {
+			modifiedAt := as[i].ModifiedAt.UTC()
+			as[i].ModifiedAt = &modifiedAt
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package web
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/news-aggregator/entity"
+)
+
+const (
+	maxIngestBodySize   = "10M"
+	maxIngestRecordSize = 10 << 20
+	mimeNDJSON          = "application/x-ndjson"
+)
+
+// ingestSourceName returns source name of the caller authenticated by
+// bearer token.
+func (s *Server) ingestSourceName(c echo.Context) (string, bool) {
+	const prefix = "Bearer "
+
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+
+	token := []byte(strings.TrimPrefix(auth, prefix))
+
+	for t, sourceName := range s.ingestTokens {
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return sourceName, true
+		}
+	}
+
+	return "", false
+}
+
+// ingestArticle is the article as accepted from ingest callers. Only the
+// content fields are accepted, the rest is set by the aggregator.
+type ingestArticle struct {
+	URL         string     `json:"url"`
+	Type        string     `json:"type"`
+	Header      string     `json:"header"`
+	PublishedAt time.Time  `json:"publishedAt"`
+	ModifiedAt  *time.Time `json:"modifiedAt"`
+	Lead        string     `json:"lead"`
+	Text        string     `json:"text"`
+	ImageURL    string     `json:"imageURL"`
+	Author      string     `json:"author"`
+	Categories  []string   `json:"categories"`
+	Tags        []string   `json:"tags"`
+}
+
+func (ia ingestArticle) article(sourceName string) entity.Article {
+	return entity.Article{
+		URL:         ia.URL,
+		Type:        ia.Type,
+		Header:      ia.Header,
+		PublishedAt: ia.PublishedAt,
+		ModifiedAt:  ia.ModifiedAt,
+		Lead:        ia.Lead,
+		Text:        ia.Text,
+		ImageURL:    ia.ImageURL,
+		Author:      ia.Author,
+		Categories:  ia.Categories,
+		Tags:        ia.Tags,
+		SourceName:  sourceName,
+	}
+}
+
+func decodeIngestBody(c echo.Context) ([]ingestArticle, error) {
+	req := c.Request()
+
+	ct := req.Header.Get(echo.HeaderContentType)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	ct = strings.TrimSpace(ct)
+
+	var ias []ingestArticle
+
+	switch ct {
+	case echo.MIMEApplicationJSON:
+		err := json.NewDecoder(req.Body).Decode(&ias)
+		if err != nil {
+			return nil, errors.New("failed to decode JSON: " + err.Error())
+		}
+
+	case mimeNDJSON, "application/ndjson":
+		scanner := bufio.NewScanner(req.Body)
+		scanner.Buffer(make([]byte, 64<<10), maxIngestRecordSize)
+
+		for line := 1; scanner.Scan(); line++ {
+			data := strings.TrimSpace(scanner.Text())
+			if data == "" {
+				continue
+			}
+
+			var ia ingestArticle
+
+			err := json.Unmarshal([]byte(data), &ia)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode line %d: %v",
+					line, err)
+			}
+
+			ias = append(ias, ia)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, errors.New("failed to read body: " + err.Error())
+		}
+
+	default:
+		return nil, errors.New("unsupported content type, expected " +
+			echo.MIMEApplicationJSON + " or " + mimeNDJSON)
+	}
+
+	return ias, nil
+}
+
+func validateIngestArticle(a ingestArticle) error {
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return errors.New("invalid url: " + err.Error())
+	}
+
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		return errors.New("url should be absolute HTTP(S) URL")
+	case strings.TrimSpace(a.Header) == "":
+		return errors.New("empty header")
+	case a.PublishedAt.IsZero():
+		return errors.New("empty publishedAt")
+	}
+
+	return nil
+}
+
+type ingestResult struct {
+	Received   int `json:"received"`
+	Stored     int `json:"stored"`
+	Updated    int `json:"updated"`
+	Duplicates int `json:"duplicates"`
+}
+
+func (s *Server) postIngest(c echo.Context) error {
+	sourceName, ok := s.ingestSourceName(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized,
+			"invalid or missing bearer token")
+	}
+
+	ias, err := decodeIngestBody(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(ias) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "no articles")
+	}
+
+	var (
+		as      []entity.Article
+		invalid []string
+	)
+
+	for i, ia := range ias {
+		err = validateIngestArticle(ia)
+		if err != nil {
+			invalid = append(invalid,
+				fmt.Sprintf("article #%d: %v", i, err))
+			continue
+		}
+		as = append(as, ia.article(sourceName))
+	}
+
+	if len(invalid) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			strings.Join(invalid, "; "))
+	}
+
+	r, err := s.ingester.Ingest(sourceName, as)
+	if err != nil {
+		return errors.New("failed to ingest articles: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ingestResult{
+		Received:   len(as),
+		Stored:     r.Stored,
+		Updated:    r.Updated,
+		Duplicates: r.Duplicates,
+	})
+}
+
+// sourceStatus is the source state for the status API. Schedule is absent
+// for not scheduled sources, like the ingested ones.
+type sourceStatus struct {
+	SourceName            string                 `json:"sourceName"`
+	Schedule              *entity.SourceSchedule `json:"schedule,omitempty"`
+	LastFetch             *entity.FetchReport    `json:"lastFetch,omitempty"`
+	SuspectedLayoutChange bool                   `json:"suspectedLayoutChange"`
+}
+
+func (s *Server) getSourcesStatus(c echo.Context) error {
+	loc, err := location(c)
+	if err != nil {
+		return err
+	}
+
+	rs, err := s.store.LatestFetchReports()
+	if err != nil {
+		return errors.New("failed to get latest fetch reports: " +
+			err.Error())
+	}
+
+	statuses := map[string]*sourceStatus{}
+
+	status := func(sourceName string) *sourceStatus {
+		ss, exists := statuses[sourceName]
+		if !exists {
+			ss = &sourceStatus{SourceName: sourceName}
+			statuses[sourceName] = ss
+		}
+		return ss
+	}
+
+	for _, sch := range s.scheduler.Schedule() {
+		sch := sch
+		sch.LastRunAt = sch.LastRunAt.In(loc)
+		sch.NextRunAt = sch.NextRunAt.In(loc)
+		status(sch.SourceName).Schedule = &sch
+	}
+
+	for _, r := range rs {
+		r := r
+		r.StartedAt = r.StartedAt.In(loc)
+		r.FinishedAt = r.FinishedAt.In(loc)
+		ss := status(r.SourceName)
+		ss.LastFetch = &r
+		ss.SuspectedLayoutChange = r.SuspectedLayoutChange
+	}
+
+	var res []sourceStatus
+
+	for _, ss := range statuses {
+		res = append(res, *ss)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].SourceName < res[j].SourceName
+	})
+
+	return c.JSON(http.StatusOK, res)
+}
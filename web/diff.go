@@ -0,0 +1,108 @@
+package web
+
+import "regexp"
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffInsert
+	diffDelete
+)
+
+type diffPart struct {
+	Op   diffOp
+	Text string
+}
+
+func (dp diffPart) Inserted() bool { return dp.Op == diffInsert }
+func (dp diffPart) Deleted() bool  { return dp.Op == diffDelete }
+
+// maxDiffCells limits memory used by diff, larger texts are shown as
+// fully replaced.
+const maxDiffCells = 4 << 20
+
+var diffTokenRe = regexp.MustCompile(`\s+|[^\s]+`)
+
+// diff returns word by word difference between a and b based on their
+// longest common subsequence.
+func diff(a, b string) []diffPart {
+	at := diffTokenRe.FindAllString(a, -1)
+	bt := diffTokenRe.FindAllString(b, -1)
+
+	if len(at)*len(bt) > maxDiffCells {
+		return mergeDiffParts([]diffPart{
+			{Op: diffDelete, Text: a},
+			{Op: diffInsert, Text: b},
+		})
+	}
+
+	// lcs[i][j] is length of the longest common subsequence of at[i:] and
+	// bt[j:].
+	lcs := make([][]int, len(at)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bt)+1)
+	}
+
+	for i := len(at) - 1; i >= 0; i-- {
+		for j := len(bt) - 1; j >= 0; j-- {
+			switch {
+			case at[i] == bt[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var (
+		parts []diffPart
+		i, j  int
+	)
+
+	for i < len(at) && j < len(bt) {
+		switch {
+		case at[i] == bt[j]:
+			parts = append(parts, diffPart{Op: diffEqual, Text: at[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			parts = append(parts, diffPart{Op: diffDelete, Text: at[i]})
+			i++
+		default:
+			parts = append(parts, diffPart{Op: diffInsert, Text: bt[j]})
+			j++
+		}
+	}
+
+	for ; i < len(at); i++ {
+		parts = append(parts, diffPart{Op: diffDelete, Text: at[i]})
+	}
+
+	for ; j < len(bt); j++ {
+		parts = append(parts, diffPart{Op: diffInsert, Text: bt[j]})
+	}
+
+	return mergeDiffParts(parts)
+}
+
+// mergeDiffParts joins adjacent parts of the same operation and drops the
+// empty ones.
+func mergeDiffParts(parts []diffPart) []diffPart {
+	var merged []diffPart
+
+	for _, p := range parts {
+		if p.Text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Op == p.Op {
+			merged[n-1].Text += p.Text
+			continue
+		}
+		merged = append(merged, p)
+	}
+
+	return merged
+}
@@ -22,12 +22,15 @@ const articlesPage = `<!DOCTYPE html>
 	<title>Новостной агрегатор</title>
 	<meta name="viewport" content="width=device-width, initial-scale=1">
 	<style>
-		input {
+		input, select {
 			width: 100%;
 			box-sizing: border-box;
 			padding: 0.5em;
 			font-size: 1.5em;
 		}
+		select {
+			margin-top: 0.3em;
+		}
 		h1 {
 			padding-top: 1em 
 		}
@@ -38,23 +41,63 @@ const articlesPage = `<!DOCTYPE html>
 			text-decoration: none;
 			color: black;
 		}
-		p, .datetime {
+		p, .datetime, .revisions, .details {
 			font-size: 1.5em;
 		}
+		.revisions, .details {
+			color: #888;
+			padding-left: 1em;
+		}
+		.lead {
+			font-weight: bold;
+		}
+		img {
+			max-width: 100%;
+		}
 	</style>
 </head>
 <body>
+	{{template "timezone"}}
 	<form action="/articles" method="get">
 		<input type="text" placeholder="Введите ключевые слова" name="q" value="{{.Query}}"/>
+		{{if .Sections}}
+			<select name="section" onchange="this.form.submit()">
+				<option value="">Все рубрики</option>
+				{{range .Sections}}
+					<option value="{{.}}" {{if eq . $.Section}}selected{{end}}>{{.}}</option>
+				{{end}}
+			</select>
+		{{end}}
 	</form>
 	{{range .Articles}}
 		<h1>
 			<a href="{{.URL}}">
-				<i>{{.SourceName}}:</i>
+				<i>{{.SourceName}}{{if .Type}} ({{.Type}}){{end}}:</i>
 				{{.Header}}
 			</a>
 		</h1>
 		<i class="datetime">{{.PublishedAt}}</i>
+		{{if .ModifiedAt}}
+			<i class="details">изменено {{.ModifiedAt}}</i>
+		{{end}}
+		{{if .Revisions}}
+			<a class="revisions" href="/articles/revisions?url={{.URL}}&sourceName={{.SourceName}}">правок: {{.Revisions}}</a>
+		{{end}}
+		{{range .Categories}}
+			<a class="details" href="/articles?q={{$.Query}}&section={{.}}">{{.}}</a>
+		{{end}}
+		{{if .Author}}
+			<i class="details">{{.Author}}</i>
+		{{end}}
+		{{if .Tags}}
+			<p class="details">{{range $i, $t := .Tags}}{{if $i}}, {{end}}#{{$t}}{{end}}</p>
+		{{end}}
+		{{if .ImageURL}}
+			<p><img src="{{.ImageURL}}" alt=""/></p>
+		{{end}}
+		{{if .Lead}}
+			<p class="lead">{{.Lead}}</p>
+		{{end}}
 		{{range .Paragraphs}}
 			<p>{{.}}</p>
 		{{end}}
@@ -69,32 +112,233 @@ type article struct {
 	entity.Article
 	Paragraphs  []string
 	PublishedAt string
+	ModifiedAt  string
 }
 
 type articlesPageData struct {
 	Query    string
+	Section  string
+	Sections []string
 	Articles []article
 }
 
 func (s *Server) getArticles(c echo.Context) error {
 	query := c.QueryParam("q")
+	section := c.QueryParam("section")
 
-	articles, err := s.store.FindArticles(query)
+	loc, err := location(c)
+	if err != nil {
+		return err
+	}
+
+	articles, err := s.store.FindArticles(query, section)
 	if err != nil {
 		return errors.New("failed to find articles: " + err.Error())
 	}
 
-	data := articlesPageData{Query: query}
+	sections, err := s.store.Sections()
+	if err != nil {
+		return errors.New("failed to get sections: " + err.Error())
+	}
+
+	data := articlesPageData{
+		Query:    query,
+		Section:  section,
+		Sections: sections,
+	}
 
 	for _, a := range articles {
+		var modifiedAt string
+		if a.ModifiedAt != nil {
+			modifiedAt = a.ModifiedAt.In(loc).Format("2006-01-02 15:04")
+		}
+
 		data.Articles = append(data.Articles, article{
 			Article:    a,
 			Paragraphs: strings.Split(a.Text, "\n"),
 			// Mon Jan 2 15:04:05 -0700 MST 2006
-			PublishedAt: a.PublishedAt.In(time.Local).
+			PublishedAt: a.PublishedAt.In(loc).
 				Format("2006-01-02 15:04"),
+			ModifiedAt: modifiedAt,
 		})
 	}
 
 	return c.Render(http.StatusOK, "articles", data)
 }
+
+// language=HTML
+const revisionsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Правки статьи</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		a {
+			text-decoration: none;
+			color: black;
+		}
+		.datetime, .text {
+			font-size: 1.5em;
+		}
+		.text {
+			white-space: pre-wrap;
+		}
+		ins {
+			background: #d4f7d4;
+			text-decoration: none;
+		}
+		del {
+			background: #f7d4d4;
+		}
+	</style>
+</head>
+<body>
+	{{template "timezone"}}
+	<h1><a href="{{.URL}}">{{.URL}}</a></h1>
+	{{range .Revisions}}
+		<h2>
+			{{if .Number}}Правка {{.Number}}{{else}}Исходная версия{{end}}
+			<i class="datetime">{{.RevisedAt}}</i>
+		</h2>
+		<h1>{{template "diff" .Header}}</h1>
+		<div class="text">{{template "diff" .Text}}</div>
+	{{else}}
+		<p><i>Правок не найдено</i></p>
+	{{end}}
+</body>
+</html>
+{{define "diff"}}{{range .}}{{if .Inserted}}<ins>{{.Text}}</ins>{{else if .Deleted}}<del>{{.Text}}</del>{{else}}{{.Text}}{{end}}{{end}}{{end}}
+`
+
+type revision struct {
+	Number    int
+	RevisedAt string
+	Header    []diffPart
+	Text      []diffPart
+}
+
+type revisionsPageData struct {
+	URL       string
+	Revisions []revision
+}
+
+func (s *Server) getRevisions(c echo.Context) error {
+	url := c.QueryParam("url")
+	if url == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty url")
+	}
+
+	sourceName := c.QueryParam("sourceName")
+	if sourceName == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty sourceName")
+	}
+
+	loc, err := location(c)
+	if err != nil {
+		return err
+	}
+
+	rs, err := s.store.Revisions(sourceName, url)
+	if err != nil {
+		return errors.New("failed to get revisions: " + err.Error())
+	}
+
+	data := revisionsPageData{URL: url}
+
+	var prev entity.ArticleRevision
+
+	for i, r := range rs {
+		if i == 0 {
+			prev = r
+		}
+
+		data.Revisions = append(data.Revisions, revision{
+			Number:    r.Number,
+			RevisedAt: formatTime(r.RevisedAt, loc),
+			Header:    diff(prev.Header, r.Header),
+			Text:      diff(prev.Text, r.Text),
+		})
+
+		prev = r
+	}
+
+	return c.Render(http.StatusOK, "revisions", data)
+}
+
+// language=HTML
+const schedulePage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Расписание источников</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		table {
+			border-collapse: collapse;
+			font-size: 1.2em;
+		}
+		th, td {
+			padding: 0.3em 0.8em;
+			text-align: left;
+			border-bottom: 1px solid #ddd;
+		}
+	</style>
+</head>
+<body>
+	{{template "timezone"}}
+	<table>
+		<tr>
+			<th>Источник</th>
+			<th>Интервал</th>
+			<th>Последний запуск</th>
+			<th>Следующий запуск</th>
+			<th>Ошибок подряд</th>
+			<th>Последняя ошибка</th>
+		</tr>
+		{{range .}}
+			<tr>
+				<td>{{.SourceName}}</td>
+				<td>{{.Interval}}</td>
+				<td>{{.LastRunAt}}</td>
+				<td>{{if .Running}}<i>выполняется</i>{{else}}{{.NextRunAt}}{{end}}</td>
+				<td>{{.Failures}}</td>
+				<td>{{.LastError}}</td>
+			</tr>
+		{{else}}
+			<tr><td colspan="6"><i>Источников нет</i></td></tr>
+		{{end}}
+	</table>
+</body>
+</html>
+`
+
+type sourceSchedule struct {
+	entity.SourceSchedule
+	LastRunAt string
+	NextRunAt string
+}
+
+func formatTime(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.In(loc).Format("2006-01-02 15:04:05")
+}
+
+func (s *Server) getSchedule(c echo.Context) error {
+	loc, err := location(c)
+	if err != nil {
+		return err
+	}
+
+	var data []sourceSchedule
+
+	for _, ss := range s.scheduler.Schedule() {
+		data = append(data, sourceSchedule{
+			SourceSchedule: ss,
+			LastRunAt:      formatTime(ss.LastRunAt, loc),
+			NextRunAt:      formatTime(ss.NextRunAt, loc),
+		})
+	}
+
+	return c.Render(http.StatusOK, "schedule", data)
+}
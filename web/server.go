@@ -17,12 +17,26 @@ import (
 )
 
 type Store interface {
-	FindArticles(query string) ([]entity.Article, error)
+	FindArticles(query, section string) ([]entity.Article, error)
+	Sections() ([]string, error)
+	LatestFetchReports() ([]entity.FetchReport, error)
+	Revisions(sourceName, url string) ([]entity.ArticleRevision, error)
+}
+
+type Scheduler interface {
+	Schedule() []entity.SourceSchedule
+}
+
+type Ingester interface {
+	Ingest(sourceName string, as []entity.Article) (entity.FetchReport, error)
 }
 
 type Server struct {
-	bindAddr string
-	store    Store
+	bindAddr     string
+	store        Store
+	scheduler    Scheduler
+	ingester     Ingester
+	ingestTokens map[string]string
 
 	echo *echo.Echo
 
@@ -31,11 +45,18 @@ type Server struct {
 	log *logrus.Entry
 }
 
-func NewServer(bindAddr string, s Store) *Server {
+// NewServer creates web server. Keys of ingestTokens are the bearer tokens
+// accepted by the ingest API, values are the source names of their
+// callers.
+func NewServer(bindAddr string, s Store, sch Scheduler, ing Ingester,
+	ingestTokens map[string]string) *Server {
 
 	return &Server{
-		bindAddr: bindAddr,
-		store:    s,
+		bindAddr:     bindAddr,
+		store:        s,
+		scheduler:    sch,
+		ingester:     ing,
+		ingestTokens: ingestTokens,
 
 		log: logrus.WithField("subsystem", "web_server"),
 	}
@@ -50,7 +71,10 @@ func (s *Server) Start() error {
 	var err error
 
 	e.Renderer, err = initRenderer(map[string]string{
-		"articles": articlesPage,
+		"articles":  articlesPage,
+		"revisions": revisionsPage,
+		"schedule":  schedulePage,
+		"timezone":  timezoneScript,
 	})
 	if err != nil {
 		return errors.New("failed to init renderer: " + err.Error())
@@ -92,6 +116,12 @@ func (s *Server) Start() error {
 
 	e.GET("/", s.getIndex)
 	e.GET("/articles", s.getArticles)
+	e.GET("/articles/revisions", s.getRevisions)
+	e.GET("/schedule", s.getSchedule)
+
+	e.GET("/api/sources/status", s.getSourcesStatus)
+	e.POST("/api/ingest", s.postIngest,
+		middleware.BodyLimit(maxIngestBodySize))
 
 	s.echo = e
 
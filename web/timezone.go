@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	timezoneParam     = "tz"
+	timezoneCookie    = "tz"
+	timezoneCookieAge = 365 * 24 * time.Hour
+)
+
+// location returns timezone to render times in. It's taken from the tz
+// query param, which is remembered in the user cookie, or from the cookie
+// itself. UTC is used by default.
+func location(c echo.Context) (*time.Location, error) {
+	name := c.QueryParam(timezoneParam)
+
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				"invalid timezone: "+err.Error())
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:    timezoneCookie,
+			Value:   url.QueryEscape(name),
+			Path:    "/",
+			Expires: time.Now().Add(timezoneCookieAge),
+		})
+
+		return loc, nil
+	}
+
+	cookie, err := c.Cookie(timezoneCookie)
+	if err != nil {
+		return time.UTC, nil
+	}
+
+	name, err = url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC, nil
+	}
+
+	return loc, nil
+}
+
+// timezoneScript remembers browser timezone in the cookie for the users
+// which have not chosen one yet.
+//
+// language=HTML
+const timezoneScript = `<script>
+	(function () {
+		if (/(^|; )tz=/.test(document.cookie) || !window.Intl) {
+			return;
+		}
+		var tz = Intl.DateTimeFormat().resolvedOptions().timeZone;
+		if (!tz) {
+			return;
+		}
+		document.cookie = "tz=" + encodeURIComponent(tz) +
+			"; path=/; max-age=31536000";
+		if (/(^|; )tz=/.test(document.cookie)) {
+			location.reload();
+		}
+	})();
+</script>`